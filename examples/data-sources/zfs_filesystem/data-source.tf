@@ -0,0 +1,10 @@
+data "zfs_filesystem" "tenant" {
+  name = "tank/tenants/acme"
+
+  lifecycle {
+    postcondition {
+      condition     = self.property_sources["compression"] == "local"
+      error_message = "Tenant roots must set compression locally rather than inherit it from ${lookup(self.inherited_from, "compression", "the default")}."
+    }
+  }
+}
@@ -1,3 +1,10 @@
 data "zfs_pool" "example" {
   name = "foo"
-}
\ No newline at end of file
+
+  lifecycle {
+    postcondition {
+      condition     = self.state == "ONLINE"
+      error_message = "Refusing to make changes to a pool which is not healthy."
+    }
+  }
+}
@@ -0,0 +1,5 @@
+# Keep the source of the next incremental send without retaining the snapshot.
+resource "zfs_bookmark" "replication" {
+  name     = "tank/data#replication"
+  snapshot = "tank/data@replication"
+}
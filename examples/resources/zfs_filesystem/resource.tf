@@ -0,0 +1,30 @@
+resource "zfs_filesystem" "projects" {
+  name       = "tank/projects"
+  mountpoint = "/srv/projects"
+  group      = "staff"
+  mode       = "2770"
+
+  compression = "zstd"
+
+  nfs_share {
+    client {
+      hosts = ["@10.0.0.0/24"]
+    }
+    client {
+      hosts  = ["backup.example.com"]
+      access = "ro"
+    }
+    sec = ["krb5p"]
+  }
+}
+
+resource "zfs_filesystem" "containers" {
+  name       = "tank/containers"
+  mountpoint = "legacy"
+
+  legacy_mount {
+    target  = "/var/lib/containers"
+    options = ["noatime"]
+    method  = "systemd"
+  }
+}
@@ -0,0 +1,5 @@
+# Take a checkpoint before adding a new vdev, and remove this resource
+# once the new layout has been validated to discard the checkpoint.
+resource "zfs_pool_checkpoint" "before_expansion" {
+  pool = "zdata"
+}
@@ -0,0 +1,6 @@
+resource "zfs_pool_import" "backup" {
+  name = "backup"
+
+  search_directories = ["/dev/disk/by-id"]
+  cachefile          = "none"
+}
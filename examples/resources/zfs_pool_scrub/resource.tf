@@ -0,0 +1,8 @@
+resource "zfs_pool_scrub" "zdata" {
+  pool = "zdata"
+  wait = true
+
+  triggers = {
+    maintenance_window = "2026-10"
+  }
+}
@@ -0,0 +1,7 @@
+# Split one side of each mirror in zdata off into a new pool, and import
+# it under /mnt/backup so it can be inspected before the disks are moved.
+resource "zfs_pool_split" "backup" {
+  pool     = "zdata"
+  new_pool = "zdata-backup"
+  altroot  = "/mnt/backup"
+}
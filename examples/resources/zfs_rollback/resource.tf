@@ -0,0 +1,13 @@
+# Reset the test environment to its golden snapshot every night.
+resource "time_rotating" "nightly" {
+  rotation_days = 1
+}
+
+resource "zfs_rollback" "test" {
+  snapshot                = "tank/test@golden"
+  destroy_newer_snapshots = true
+
+  triggers = {
+    nightly = time_rotating.nightly.id
+  }
+}
@@ -0,0 +1,5 @@
+# Keep the replication baseline from being destroyed by retention tools.
+resource "zfs_snapshot_hold" "baseline" {
+  snapshot = "tank/data@replication-baseline"
+  tag      = "replication"
+}
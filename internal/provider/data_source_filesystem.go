@@ -27,6 +27,11 @@ func dataSourceFilesystem() *schema.Resource {
 				Type:        schema.TypeString,
 				Computed:    true,
 			},
+			"mounted": {
+				Description: "Whether the filesystem is currently mounted.",
+				Type:        schema.TypeBool,
+				Computed:    true,
+			},
 			"owner": {
 				Description: "Username of the owner of the mountpoint",
 				Type:        schema.TypeString,
@@ -47,8 +52,10 @@ func dataSourceFilesystem() *schema.Resource {
 				Type:        schema.TypeInt,
 				Computed:    true,
 			},
-			"properties":     &propertiesSchema,
-			"raw_properties": &rawPropertiesSchema,
+			"properties":       &propertiesSchema,
+			"raw_properties":   &rawPropertiesSchema,
+			"property_sources": &propertySourcesSchema,
+			"inherited_from":   &inheritedFromSchema,
 		},
 	}
 }
@@ -84,6 +91,10 @@ func dataSourceFilesystemRead(ctx context.Context, d *schema.ResourceData, meta
 
 	d.SetId(filesystem.guid)
 
+	if err := d.Set("mounted", filesystem.mounted == "yes"); err != nil {
+		return diag.FromErr(err)
+	}
+
 	if filesystem.mountpoint != "" && filesystem.mountpoint != "none" && filesystem.mountpoint != "legacy" {
 		owner, err := getFileOwnership(config, filesystem.mountpoint)
 		if err != nil {
@@ -31,8 +31,90 @@ func dataSourcePool() *schema.Resource {
 				Type:        schema.TypeString,
 				Computed:    true,
 			},
-			"properties":     &propertiesSchema,
-			"raw_properties": &rawPropertiesSchema,
+			"state": {
+				Description: "Health of the pool, e.g. `ONLINE`, `DEGRADED` or `FAULTED`.",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+			"scan_function": {
+				Description: "Type of the last scan performed on the pool, either `scrub` or `resilver`.",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+			"scan_state": {
+				Description: "State of the last scan. One of `none`, `scanning`, `finished`, `canceled` or `paused`.",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+			"scan_progress": {
+				Description: "Percentage of the currently running scan which has completed.",
+				Type:        schema.TypeFloat,
+				Computed:    true,
+			},
+			"scan_errors": {
+				Description: "Number of errors encountered by the last finished scan.",
+				Type:        schema.TypeInt,
+				Computed:    true,
+			},
+			"vdev": {
+				Description: "Status of every vdev in the pool, flattened in the order reported by `zpool status`.",
+				Type:        schema.TypeList,
+				Computed:    true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"name": {
+							Description: "Name or device path of the vdev.",
+							Type:        schema.TypeString,
+							Computed:    true,
+						},
+						"parent": {
+							Description: "Name of the vdev (or allocation class) containing this vdev.",
+							Type:        schema.TypeString,
+							Computed:    true,
+						},
+						"class": {
+							Description: "Allocation class of the vdev. One of `data`, `logs`, `cache`, `spares`, `special` or `dedup`.",
+							Type:        schema.TypeString,
+							Computed:    true,
+						},
+						"state": {
+							Description: "State of the vdev, e.g. `ONLINE`, `DEGRADED`, `FAULTED` or `AVAIL`.",
+							Type:        schema.TypeString,
+							Computed:    true,
+						},
+						"read_errors": {
+							Description: "Number of read errors.",
+							Type:        schema.TypeInt,
+							Computed:    true,
+						},
+						"write_errors": {
+							Description: "Number of write errors.",
+							Type:        schema.TypeInt,
+							Computed:    true,
+						},
+						"checksum_errors": {
+							Description: "Number of checksum errors.",
+							Type:        schema.TypeInt,
+							Computed:    true,
+						},
+						"message": {
+							Description: "Additional status message for the vdev, if any.",
+							Type:        schema.TypeString,
+							Computed:    true,
+						},
+					},
+				},
+			},
+			"permanent_errors": {
+				Description: "Files with permanent errors in the pool.",
+				Type:        schema.TypeList,
+				Computed:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+			},
+			"properties":       &propertiesSchema,
+			"raw_properties":   &rawPropertiesSchema,
+			"property_sources": &propertySourcesSchema,
+			"inherited_from":   &inheritedFromSchema,
 		},
 	}
 }
@@ -46,7 +128,7 @@ func dataSourcePoolRead(ctx context.Context, d *schema.ResourceData, meta interf
 
 	poolName := d.Get("name").(string)
 
-	pool, err := describePool(config, poolName, getPropertyNames(d))
+	pool, err := describePool(config, poolName, []string{})
 	if err != nil {
 		return diag.FromErr(err)
 	}
@@ -55,7 +137,55 @@ func dataSourcePoolRead(ctx context.Context, d *schema.ResourceData, meta interf
 		return diag.FromErr(err)
 	}
 
+	status, err := readPoolStatus(config, poolName)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	if diags := populatePoolStatus(d, *status); diags.HasError() {
+		return diags
+	}
+
 	d.SetId(pool.guid)
 
 	return diags
 }
+
+func populatePoolStatus(d *schema.ResourceData, status PoolStatus) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	if err := d.Set("state", status.state); err != nil {
+		return diag.FromErr(err)
+	}
+
+	if err := d.Set("scan_function", status.scan.function); err != nil {
+		return diag.FromErr(err)
+	}
+
+	if err := d.Set("scan_state", string(status.scan.state)); err != nil {
+		return diag.FromErr(err)
+	}
+
+	if err := d.Set("scan_progress", status.scan.progress); err != nil {
+		return diag.FromErr(err)
+	}
+
+	if err := d.Set("scan_errors", status.scan.errors); err != nil {
+		return diag.FromErr(err)
+	}
+
+	vdevs := make([]map[string]interface{}, len(status.vdevs))
+	for vdev_id, vdev := range status.vdevs {
+		vdevs[vdev_id] = flattenVdevStatus(vdev)
+	}
+
+	if err := d.Set("vdev", vdevs); err != nil {
+		return diag.FromErr(err)
+	}
+
+	if err := d.Set("permanent_errors", status.errors); err != nil {
+		return diag.FromErr(err)
+	}
+
+	return diags
+}
@@ -30,3 +30,85 @@ data "zfs_pool" "foo" {
   name = "bar"
 }
 `
+
+const testPoolStatusDegraded = `  pool: aquarium
+ state: DEGRADED
+status: One or more devices could not be used because the label is missing or
+	invalid.  Sufficient replicas exist for the pool to continue
+	functioning in a degraded state.
+action: Replace the device using 'zpool replace'.
+  scan: resilver in progress since Sun Oct 18 00:24:01 2026
+	1.23G scanned at 100M/s, 500M issued at 50M/s, 10G total
+	500M resilvered, 5.00% done, 00:03:00 to go
+config:
+
+	NAME           STATE     READ WRITE CKSUM
+	aquarium       DEGRADED     0     0     0
+	  mirror-0     DEGRADED     0     0     0
+	    /dev/sda1  ONLINE       0     0     2
+	    /dev/sdb1  UNAVAIL      3     1     0  cannot open
+	  /dev/sdc1    ONLINE       0     0     0
+	logs
+	  /dev/sdd1    ONLINE       0     0     0
+	spares
+	  /dev/sde1    AVAIL
+
+errors: Permanent errors have been detected in the following files:
+
+        /aquarium/fish.db
+        aquarium/tank:<0x0>
+`
+
+// TestParsePoolStatus_Degraded verifies that the vdev tree, error counters,
+// scan progress and permanent errors are all extracted from zpool status.
+func TestParsePoolStatus_Degraded(t *testing.T) {
+	status, err := parsePoolStatus(testPoolStatusDegraded)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if status.state != "DEGRADED" {
+		t.Fatalf("expected pool state DEGRADED, got %q", status.state)
+	}
+
+	if status.scan.function != "resilver" || status.scan.state != ScanScanning || status.scan.progress != 5.0 {
+		t.Fatalf("unexpected scan status: %#v", status.scan)
+	}
+
+	expected := []VdevStatus{
+		{name: "mirror-0", parent: "aquarium", class: "data", state: "DEGRADED"},
+		{name: "/dev/sda1", parent: "mirror-0", class: "data", state: "ONLINE", checksum: 2},
+		{name: "/dev/sdb1", parent: "mirror-0", class: "data", state: "UNAVAIL", read: 3, write: 1, message: "cannot open"},
+		{name: "/dev/sdc1", parent: "aquarium", class: "data", state: "ONLINE"},
+		{name: "/dev/sdd1", parent: "logs", class: "logs", state: "ONLINE"},
+		{name: "/dev/sde1", parent: "spares", class: "spares", state: "AVAIL"},
+	}
+
+	if len(status.vdevs) != len(expected) {
+		t.Fatalf("expected %d vdevs, got %d: %#v", len(expected), len(status.vdevs), status.vdevs)
+	}
+	for i, vdev := range expected {
+		if status.vdevs[i] != vdev {
+			t.Fatalf("vdev %d: expected %#v, got %#v", i, vdev, status.vdevs[i])
+		}
+	}
+
+	if len(status.errors) != 2 || status.errors[0] != "/aquarium/fish.db" || status.errors[1] != "aquarium/tank:<0x0>" {
+		t.Fatalf("unexpected permanent errors: %#v", status.errors)
+	}
+}
+
+// TestParsePoolStatus_NoErrors verifies that a healthy pool reports
+// no permanent errors.
+func TestParsePoolStatus_NoErrors(t *testing.T) {
+	stdout := "  pool: aquarium\n state: ONLINE\n  scan: none requested\nconfig:\n\n\tNAME        STATE     READ WRITE CKSUM\n\taquarium    ONLINE       0     0     0\n\t  /dev/sda  ONLINE       0     0     0\n\nerrors: No known data errors\n"
+
+	status, err := parsePoolStatus(stdout)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if status.state != "ONLINE" || len(status.vdevs) != 1 || len(status.errors) != 0 {
+		t.Fatalf("unexpected pool status: %#v", status)
+	}
+}
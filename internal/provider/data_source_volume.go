@@ -27,8 +27,10 @@ func dataSourceVolume() *schema.Resource {
 				Type:        schema.TypeString,
 				Computed:    true,
 			},
-			"properties":     &propertiesSchema,
-			"raw_properties": &rawPropertiesSchema,
+			"properties":       &propertiesSchema,
+			"raw_properties":   &rawPropertiesSchema,
+			"property_sources": &propertySourcesSchema,
+			"inherited_from":   &inheritedFromSchema,
 		},
 	}
 }
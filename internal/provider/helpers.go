@@ -4,17 +4,24 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/alessio/shellescape"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 )
 
 func callSshCommand(config *Config, cmd string, args ...interface{}) (string, error) {
+	return callSshCommandWithTimeout(config, 60*time.Second, cmd, args...)
+}
+
+// callSshCommandWithTimeout is like callSshCommand, but for long-running commands such as `zpool wait`.
+func callSshCommandWithTimeout(config *Config, timeout time.Duration, cmd string, args ...interface{}) (string, error) {
 	cmd = fmt.Sprintf(cmd, args...)
 	log.Printf("[DEBUG] ssh command: %s %s", config.command_prefix, cmd)
-	stdout, stderr, done, err := config.ssh.Run(config.command_prefix+" "+cmd, 60*time.Second)
+	stdout, stderr, done, err := config.ssh.Run(config.command_prefix+" "+cmd, timeout)
 
 	if stderr != "" {
 		if strings.Contains(stderr, "dataset does not exist") {
@@ -42,10 +49,11 @@ type Ownership struct {
 	groupName string
 	uid       int
 	gid       int
+	mode      string
 }
 
 func getFileOwnership(config *Config, path string) (*Ownership, error) {
-	output, err := callSshCommand(config, "stat -c '%%U,%%G,%%u,%%g' '%s'", path)
+	output, err := callSshCommand(config, "stat -c '%%U,%%G,%%u,%%g,%%a' '%s'", path)
 
 	if err != nil {
 		return nil, err
@@ -68,17 +76,153 @@ func getFileOwnership(config *Config, path string) (*Ownership, error) {
 		groupName: values[1],
 		uid:       uid,
 		gid:       gid,
+		mode:      values[4],
 	}, nil
 }
 
-func parseVdevSpecification(mirrors interface{}, devices interface{}) string {
+var filemodePattern = regexp.MustCompile(`^0?[0-7]{3,4}$`)
+
+// parseFilemode parses an octal file mode, such that 0755 and 755 (as reported by stat) compare equal.
+func parseFilemode(mode string) (uint64, error) {
+	if !filemodePattern.MatchString(mode) {
+		return 0, fmt.Errorf("%q is not a valid octal file mode", mode)
+	}
+	return strconv.ParseUint(mode, 8, 32)
+}
+
+func suppressEquivalentFilemodeDiff(k, old, new string, d *schema.ResourceData) bool {
+	oldMode, err := parseFilemode(old)
+	if err != nil {
+		return false
+	}
+	newMode, err := parseFilemode(new)
+	if err != nil {
+		return false
+	}
+	return oldMode == newMode
+}
+
+const diskByIdDirectory = "/dev/disk/by-id"
+
+// resolveDevicePath turns a device block into the path of the device on the host, resolving the by_id, serial and wwn
+// selectors to their /dev/disk/by-id equivalents.
+func resolveDevicePath(config *Config, device map[string]interface{}) (string, error) {
+	selectors := make([]string, 0)
+	for _, selector := range []string{"by_id", "serial", "wwn"} {
+		if value, ok := device[selector].(string); ok && value != "" {
+			selectors = append(selectors, selector)
+		}
+	}
+	path, _ := device["path"].(string)
+
+	if len(selectors) > 1 {
+		return "", fmt.Errorf("only one of %s can be used to select a device", strings.Join(selectors, ", "))
+	}
+	if len(selectors) == 0 {
+		if path == "" {
+			return "", errors.New("one of path, by_id, serial or wwn must be set for each device")
+		}
+		return path, nil
+	}
+
+	switch selectors[0] {
+	case "by_id":
+		return diskByIdDirectory + "/" + device["by_id"].(string), nil
+	case "wwn":
+		return diskByIdDirectory + "/wwn-" + device["wwn"].(string), nil
+	default:
+		stdout, err := callSshCommand(config, "ls -1 %s", diskByIdDirectory)
+		if err != nil {
+			return "", err
+		}
+		name, err := findDiskBySerial(strings.Split(stdout, "\n"), device["serial"].(string))
+		if err != nil {
+			return "", err
+		}
+		return diskByIdDirectory + "/" + name, nil
+	}
+}
+
+// findDiskBySerial finds the /dev/disk/by-id entry of the whole disk with the given serial number. The by-id names are
+// made up of the bus, model and serial, e.g. ata-Samsung_SSD_860_EVO_500GB_S3Z9NB0K123456.
+func findDiskBySerial(entries []string, serial string) (string, error) {
+	for _, entry := range entries {
+		if partitionSuffixPattern.MatchString(entry) || strings.HasPrefix(entry, "wwn-") {
+			continue
+		}
+		if strings.HasSuffix(entry, "_"+serial) || strings.HasSuffix(entry, "-"+serial) {
+			return entry, nil
+		}
+	}
+	return "", fmt.Errorf("no disk with serial %s found in %s", serial, diskByIdDirectory)
+}
+
+var (
+	partitionSuffixPattern = regexp.MustCompile(`-part\d+$`)
+	// nvme, mmcblk and loop devices end in a digit themselves, so their partitions are separated by a p.
+	numberedPartitionPattern = regexp.MustCompile(`^(/dev/(?:nvme\d+n\d+|mmcblk\d+|loop\d+))p\d+$`)
+	plainPartitionPattern    = regexp.MustCompile(`^(/dev/(?:sd|vd|xvd|hd)[a-z]+)\d+$`)
+)
+
+// normalizeDevicePath strips the partition suffix from a device path, so that the partitions zfs creates on whole
+// disks (e.g. /dev/sda1 or /dev/disk/by-id/...-part1) compare equal to the disk they were created on.
+func normalizeDevicePath(path string) string {
+	if partitionSuffixPattern.MatchString(path) {
+		return partitionSuffixPattern.ReplaceAllString(path, "")
+	}
+	if match := numberedPartitionPattern.FindStringSubmatch(path); match != nil {
+		return match[1]
+	}
+	if match := plainPartitionPattern.FindStringSubmatch(path); match != nil {
+		return match[1]
+	}
+	return path
+}
+
+func devicePathsMatch(configured string, actual string) bool {
+	return configured == actual || normalizeDevicePath(actual) == configured
+}
+
+// getVdevPath returns the path to hand to zpool for a device block, which is the partition if one is defined.
+func getVdevPath(device map[string]interface{}) string {
+	path := device["path"].(string)
+	if partitions, ok := device["partition"].([]interface{}); ok && len(partitions) > 0 && partitions[0] != nil {
+		return partitionPath(path, partitions[0].(map[string]interface{})["number"].(int))
+	}
+	return path
+}
+
+// partitionPath is the inverse of normalizeDevicePath, returning the path of a partition on the given disk.
+func partitionPath(path string, number int) string {
+	switch {
+	case strings.HasPrefix(path, diskByIdDirectory+"/"):
+		return fmt.Sprintf("%s-part%d", path, number)
+	case numberedPartitionPattern.MatchString(fmt.Sprintf("%sp%d", path, number)):
+		return fmt.Sprintf("%sp%d", path, number)
+	default:
+		return fmt.Sprintf("%s%d", path, number)
+	}
+}
+
+func parseVdevSpecification(mirrors interface{}, raidz interface{}, devices interface{}) string {
 	vdevs := ""
 	if mirrors != nil {
 		for _, mirror := range mirrors.([]interface{}) {
 			devices := mirror.(map[string]interface{})["device"]
 			vdevs = vdevs + " mirror"
 			for _, device := range devices.([]interface{}) {
-				path := device.(map[string]interface{})["path"].(string)
+				path := getVdevPath(device.(map[string]interface{}))
+				vdevs = vdevs + " " + path
+			}
+		}
+	}
+
+	if raidz != nil {
+		for _, vdev := range raidz.([]interface{}) {
+			vdev := vdev.(map[string]interface{})
+			vdevs = vdevs + fmt.Sprintf(" raidz%d", vdev["parity"].(int))
+			for _, device := range vdev["device"].([]interface{}) {
+				path := getVdevPath(device.(map[string]interface{}))
 				vdevs = vdevs + " " + path
 			}
 		}
@@ -86,7 +230,7 @@ func parseVdevSpecification(mirrors interface{}, devices interface{}) string {
 
 	if devices != nil {
 		for _, device := range devices.([]interface{}) {
-			path := device.(map[string]interface{})["path"].(string)
+			path := getVdevPath(device.(map[string]interface{}))
 			vdevs = vdevs + " " + path
 		}
 	}
@@ -95,6 +239,82 @@ func parseVdevSpecification(mirrors interface{}, devices interface{}) string {
 	return vdevs
 }
 
+// Auxiliary vdevs don't store any pool data, and are identified by their class keyword in the vdev specification.
+var auxiliaryVdevClasses = []string{"log", "cache", "spare"}
+
+func parseAuxiliaryVdevSpecification(class string, devices []interface{}) string {
+	if len(devices) == 0 {
+		return ""
+	}
+
+	vdevs := " " + class
+	for _, device := range devices {
+		vdevs = vdevs + " " + getVdevPath(device.(map[string]interface{}))
+	}
+
+	log.Printf("[DEBUG] %s vdev specification: %s", class, vdevs)
+	return vdevs
+}
+
+var sizePattern = regexp.MustCompile(`^(\d+(?:\.\d+)?)\s*([KMGTPE]?)(?:I?B)?$`)
+
+// parseSize parses a size the way zfs does, accepting raw byte counts as well as binary suffixes such as 10G or 10GiB.
+func parseSize(value string) (uint64, error) {
+	match := sizePattern.FindStringSubmatch(strings.ToUpper(strings.TrimSpace(value)))
+	if match == nil {
+		return 0, fmt.Errorf("%q is not a valid size", value)
+	}
+
+	number, err := strconv.ParseFloat(match[1], 64)
+	if err != nil {
+		return 0, err
+	}
+
+	multiplier := uint64(1)
+	if match[2] != "" {
+		multiplier = 1 << (10 * (strings.Index("KMGTPE", match[2]) + 1))
+	}
+
+	return uint64(number * float64(multiplier)), nil
+}
+
+func validateSize(value interface{}, k string) ([]string, []error) {
+	if _, err := parseSize(value.(string)); err != nil {
+		return nil, []error{fmt.Errorf("%s: %w", k, err)}
+	}
+	return nil, nil
+}
+
+// suppressEquivalentSizeDiff hides differences between sizes written in different units, such as the raw byte count
+// zfs reports and the 10G in the configuration.
+func suppressEquivalentSizeDiff(k, old, new string, d *schema.ResourceData) bool {
+	oldSize, err := parseSize(old)
+	if err != nil {
+		return false
+	}
+	newSize, err := parseSize(new)
+	if err != nil {
+		return false
+	}
+	return oldSize == newSize
+}
+
+// suppressAutoRefreservationDiff is like suppressEquivalentSizeDiff, but also hides the difference between
+// refreservation = "auto" and the size zfs computes for it.
+func suppressAutoRefreservationDiff(k, old, new string, d *schema.ResourceData) bool {
+	if new == "auto" && isAutoRefreservation(old) {
+		return true
+	}
+	return suppressEquivalentSizeDiff(k, old, new, d)
+}
+
+// isAutoRefreservation reports whether the refreservation read back from zfs could have been set to "auto", which zfs
+// reports as the reservation it computed from the volume size.
+func isAutoRefreservation(value string) bool {
+	size, err := parseSize(value)
+	return err == nil && size > 0
+}
+
 func parsePropertyBlocks(options []interface{}) map[string]string {
 	properties := make(map[string]string)
 
@@ -122,3 +342,128 @@ func getPropertyNames(d *schema.ResourceData) []string {
 	}
 	return names
 }
+
+// mergeProperties combines several property maps, with later maps taking precedence.
+func mergeProperties(maps ...map[string]string) map[string]string {
+	merged := make(map[string]string)
+	for _, properties := range maps {
+		for name, value := range properties {
+			merged[name] = value
+		}
+	}
+	return merged
+}
+
+type AclEntry struct {
+	tag         string
+	qualifier   string
+	permissions string
+	isDefault   bool
+}
+
+func (e AclEntry) String() string {
+	spec := fmt.Sprintf("%s:%s:%s", e.tag, e.qualifier, e.permissions)
+	if e.isDefault {
+		return "default:" + spec
+	}
+	return spec
+}
+
+// parseFacl parses the output of `getfacl -c -p`, such as:
+//
+//	user::rwx
+//	user:alice:rwx
+//	group::r-x
+//	mask::rwx		#effective:r-x
+//	other::r-x
+//	default:user::rwx
+func parseFacl(stdout string) ([]AclEntry, error) {
+	entries := make([]AclEntry, 0)
+	for _, line := range strings.Split(stdout, "\n") {
+		if comment := strings.Index(line, "#"); comment >= 0 {
+			line = line[:comment]
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		entry := AclEntry{}
+		if rest, ok := strings.CutPrefix(line, "default:"); ok {
+			entry.isDefault = true
+			line = rest
+		}
+
+		parts := strings.Split(line, ":")
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("unrecognized acl entry %s", line)
+		}
+		entry.tag, entry.qualifier, entry.permissions = parts[0], parts[1], parts[2]
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+func getFileAcl(config *Config, path string) ([]AclEntry, error) {
+	stdout, err := callSshCommand(config, "getfacl -c -p '%s'", path)
+	if err != nil {
+		return nil, err
+	}
+	return parseFacl(stdout)
+}
+
+// setFileAcl replaces the extended and default acl entries of a file. Entries for the owner, group and other are
+// part of the file mode, and are only changed if given.
+func setFileAcl(config *Config, path string, entries []AclEntry) error {
+	access := make([]string, 0)
+	defaults := make([]string, 0)
+	for _, entry := range entries {
+		if entry.isDefault {
+			defaults = append(defaults, AclEntry{tag: entry.tag, qualifier: entry.qualifier, permissions: entry.permissions}.String())
+		} else {
+			access = append(access, entry.String())
+		}
+	}
+
+	if len(access) > 0 {
+		if _, err := callSshCommand(config, "setfacl -b -m %s '%s'", shellescape.Quote(strings.Join(access, ",")), path); err != nil {
+			return err
+		}
+	} else if _, err := callSshCommand(config, "setfacl -b '%s'", path); err != nil {
+		return err
+	}
+
+	if len(defaults) > 0 {
+		_, err := callSshCommand(config, "setfacl -k -d -m %s '%s'", shellescape.Quote(strings.Join(defaults, ",")), path)
+		return err
+	}
+	_, err := callSshCommand(config, "setfacl -k '%s'", path)
+	return err
+}
+
+// parseNfs4Acl parses the output of nfs4_getfacl, which lists one access control entry per line such as
+// A::OWNER@:rwaDxtTcCy, preceded by a comment naming the file.
+func parseNfs4Acl(stdout string) []string {
+	entries := make([]string, 0)
+	for _, line := range strings.Split(stdout, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		entries = append(entries, line)
+	}
+	return entries
+}
+
+func getNfs4Acl(config *Config, path string) ([]string, error) {
+	stdout, err := callSshCommand(config, "nfs4_getfacl '%s'", path)
+	if err != nil {
+		return nil, err
+	}
+	return parseNfs4Acl(stdout), nil
+}
+
+func setNfs4Acl(config *Config, path string, entries []string) error {
+	_, err := callSshCommand(config, "nfs4_setfacl -s %s '%s'", shellescape.Quote(strings.Join(entries, ",")), path)
+	return err
+}
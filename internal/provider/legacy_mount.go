@@ -0,0 +1,302 @@
+package provider
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+
+	"github.com/alessio/shellescape"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+var legacyMountSchema = schema.Schema{
+	Description: "Mount a filesystem with `mountpoint = \"legacy\"` through an /etc/fstab entry or a systemd mount unit.",
+	Type:        schema.TypeList,
+	Optional:    true,
+	MaxItems:    1,
+	Elem: &schema.Resource{
+		Schema: map[string]*schema.Schema{
+			"target": {
+				Description:      "Path to mount the filesystem at.",
+				Type:             schema.TypeString,
+				Required:         true,
+				ValidateDiagFunc: validation.ToDiagFunc(validation.StringMatch(absolutePathPattern, "must be an absolute path")),
+			},
+			"options": {
+				Description: "Mount options, such as `noatime` or `nofail`.",
+				Type:        schema.TypeList,
+				Optional:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+			},
+			"method": {
+				Description:      "How to persist the mount. One of `fstab` or `systemd`. Defaults to `fstab`",
+				Type:             schema.TypeString,
+				Optional:         true,
+				Default:          "fstab",
+				ValidateDiagFunc: validation.ToDiagFunc(validation.StringInSlice([]string{"fstab", "systemd"}, false)),
+			},
+		},
+	},
+}
+
+var absolutePathPattern = regexp.MustCompile(`^/`)
+
+const (
+	fstabPath            = "/etc/fstab"
+	systemdUnitDirectory = "/etc/systemd/system"
+)
+
+type LegacyMount struct {
+	target  string
+	options []string
+	method  string
+}
+
+func expandLegacyMount(d *schema.ResourceData) *LegacyMount {
+	blocks := d.Get("legacy_mount").([]interface{})
+	if len(blocks) == 0 || blocks[0] == nil {
+		return nil
+	}
+	return expandLegacyMountBlock(blocks[0].(map[string]interface{}))
+}
+
+func expandLegacyMountBlock(block map[string]interface{}) *LegacyMount {
+	return &LegacyMount{
+		target:  block["target"].(string),
+		options: stringList(block["options"]),
+		method:  block["method"].(string),
+	}
+}
+
+func flattenLegacyMount(mount *LegacyMount) []interface{} {
+	if mount == nil {
+		return []interface{}{}
+	}
+	return []interface{}{map[string]interface{}{
+		"target":  mount.target,
+		"options": interfaceList(mount.options),
+		"method":  mount.method,
+	}}
+}
+
+// getMountPath returns the path the filesystem is mounted at, which for legacy filesystems is the legacy_mount target.
+func getMountPath(d *schema.ResourceData, mountpoint string) string {
+	if mount := expandLegacyMount(d); mountpoint == "legacy" && mount != nil {
+		return mount.target
+	}
+	return mountpoint
+}
+
+func (m *LegacyMount) serializedOptions() string {
+	if len(m.options) == 0 {
+		return "defaults"
+	}
+	return strings.Join(m.options, ",")
+}
+
+func parseMountOptions(options string) []string {
+	if options == "" || options == "defaults" {
+		return []string{}
+	}
+	return strings.Split(options, ",")
+}
+
+// fstab fields are separated by whitespace, so whitespace within a field is written as an octal escape, see man fstab.
+var (
+	fstabEscaper   = strings.NewReplacer("\\", "\\134", " ", "\\040", "\t", "\\011", "\n", "\\012")
+	fstabUnescaper = strings.NewReplacer("\\134", "\\", "\\040", " ", "\\011", "\t", "\\012", "\n")
+)
+
+func renderFstabEntry(datasetName string, mount *LegacyMount) string {
+	return fmt.Sprintf("%s %s zfs %s 0 0", datasetName, fstabEscaper.Replace(mount.target), mount.serializedOptions())
+}
+
+func parseFstabEntry(line string) *LegacyMount {
+	fields := strings.Fields(line)
+	if len(fields) < 4 {
+		return nil
+	}
+	return &LegacyMount{target: fstabUnescaper.Replace(fields[1]), options: parseMountOptions(fields[3]), method: "fstab"}
+}
+
+func renderMountUnit(datasetName string, mount *LegacyMount) string {
+	return fmt.Sprintf(`[Unit]
+Description=Mount zfs filesystem %s
+
+[Mount]
+What=%s
+Where=%s
+Type=zfs
+Options=%s
+
+[Install]
+WantedBy=local-fs.target
+`, datasetName, datasetName, mount.target, mount.serializedOptions())
+}
+
+func parseMountUnit(content string) *LegacyMount {
+	mount := &LegacyMount{method: "systemd", options: []string{}}
+	for _, line := range strings.Split(content, "\n") {
+		key, value, _ := strings.Cut(strings.TrimSpace(line), "=")
+		switch key {
+		case "Where":
+			mount.target = value
+		case "Options":
+			mount.options = parseMountOptions(value)
+		}
+	}
+	if mount.target == "" {
+		return nil
+	}
+	return mount
+}
+
+// callShellScript runs a script through sh, so that redirections are run with the command prefix as well.
+func callShellScript(config *Config, script string) (string, error) {
+	return callSshCommand(config, "sh -c %s", shellescape.Quote(script))
+}
+
+// reloadSystemdScript has systemd pick up changes to the fstab, on hosts booted with systemd.
+const reloadSystemdScript = "if [ -d /run/systemd/system ]; then systemctl daemon-reload; fi"
+
+// lockedFstabScript runs the script while holding a lock on the fstab, since filesystems are created in parallel. The
+// fstab is rewritten in place, so the lock stays on the same file.
+func lockedFstabScript(script string) string {
+	return fmt.Sprintf("(flock 9 && %s) 9<%s", script, fstabPath)
+}
+
+// removeFstabEntryScript removes any fstab entries for the dataset, keeping the permissions of the fstab.
+func removeFstabEntryScript(datasetName string) string {
+	return fmt.Sprintf(`tmp=$(mktemp) && awk -v ds=%s '$1 != ds' %s > "$tmp" && cat "$tmp" > %s; rc=$?; rm -f "$tmp"; [ $rc -eq 0 ]`,
+		shellescape.Quote(datasetName), fstabPath, fstabPath)
+}
+
+func getMountUnitName(config *Config, target string) (string, error) {
+	return callSshCommand(config, "systemd-escape -p --suffix=mount %s", shellescape.Quote(target))
+}
+
+func installLegacyMount(config *Config, datasetName string, mount *LegacyMount) error {
+	log.Printf("[DEBUG] installing %s mount of %s at %s", mount.method, datasetName, mount.target)
+	switch mount.method {
+	case "systemd":
+		unit, err := getMountUnitName(config, mount.target)
+		if err != nil {
+			return err
+		}
+		script := fmt.Sprintf("printf '%%s' %s > %s/%s", shellescape.Quote(renderMountUnit(datasetName, mount)), systemdUnitDirectory, unit)
+		if _, err := callShellScript(config, script); err != nil {
+			return err
+		}
+		if _, err := callSshCommand(config, "systemctl daemon-reload"); err != nil {
+			return err
+		}
+		_, err = callSshCommand(config, "systemctl enable -q %s", unit)
+		return err
+	default:
+		entry := fmt.Sprintf("%s && printf '%%s\\n' %s >> %s", removeFstabEntryScript(datasetName), shellescape.Quote(renderFstabEntry(datasetName, mount)), fstabPath)
+		script := fmt.Sprintf("mkdir -p %s && %s && %s", shellescape.Quote(mount.target), lockedFstabScript(entry), reloadSystemdScript)
+		_, err := callShellScript(config, script)
+		return err
+	}
+}
+
+func removeLegacyMount(config *Config, datasetName string, mount *LegacyMount) error {
+	log.Printf("[DEBUG] removing %s mount of %s at %s", mount.method, datasetName, mount.target)
+	switch mount.method {
+	case "systemd":
+		unit, err := getMountUnitName(config, mount.target)
+		if err != nil {
+			return err
+		}
+		if _, err := callSshCommand(config, "systemctl disable -q --now %s", unit); err != nil {
+			return err
+		}
+		if _, err := callSshCommand(config, "rm -f %s/%s", systemdUnitDirectory, unit); err != nil {
+			return err
+		}
+		_, err = callSshCommand(config, "systemctl daemon-reload")
+		return err
+	default:
+		if mounted, err := isMountedAt(config, datasetName, mount.target); err != nil {
+			return err
+		} else if mounted {
+			if err := unmountLegacy(config, mount, false); err != nil {
+				return err
+			}
+		}
+		_, err := callShellScript(config, lockedFstabScript(removeFstabEntryScript(datasetName))+" && "+reloadSystemdScript)
+		return err
+	}
+}
+
+// readLegacyMount reads back the fstab entry or mount unit of the dataset, returning nil if it no longer exists.
+func readLegacyMount(config *Config, datasetName string, mount *LegacyMount) (*LegacyMount, error) {
+	switch mount.method {
+	case "systemd":
+		unit, err := getMountUnitName(config, mount.target)
+		if err != nil {
+			return nil, err
+		}
+		content, err := callShellScript(config, fmt.Sprintf("cat %s/%s 2>/dev/null || true", systemdUnitDirectory, unit))
+		if err != nil {
+			return nil, err
+		}
+		return parseMountUnit(content), nil
+	default:
+		line, err := callSshCommand(config, "awk -v ds=%s '$1 == ds' %s", shellescape.Quote(datasetName), fstabPath)
+		if err != nil {
+			return nil, err
+		}
+		return parseFstabEntry(line), nil
+	}
+}
+
+// isMountedAt checks /proc/mounts, which escapes whitespace like the fstab. The target is passed through the
+// environment, as awk -v would expand the escapes.
+func isMountedAt(config *Config, datasetName string, target string) (bool, error) {
+	stdout, err := callSshCommand(config, "t=%s awk '$2 == ENVIRON[\"t\"] {print $1}' /proc/mounts", shellescape.Quote(fstabEscaper.Replace(target)))
+	if err != nil {
+		return false, err
+	}
+	for _, source := range strings.Split(stdout, "\n") {
+		if source == datasetName {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// mountLegacy mounts the filesystem without looking it up in the fstab, as mount complains on stderr about fstab
+// changes systemd hasn't picked up yet.
+func mountLegacy(config *Config, datasetName string, mount *LegacyMount) error {
+	if mount.method == "systemd" {
+		unit, err := getMountUnitName(config, mount.target)
+		if err != nil {
+			return err
+		}
+		_, err = callSshCommand(config, "systemctl start %s", unit)
+		return err
+	}
+	_, err := callSshCommand(config, "mount -t zfs -o %s %s %s", shellescape.Quote(mount.serializedOptions()), datasetName, shellescape.Quote(mount.target))
+	return err
+}
+
+func unmountLegacy(config *Config, mount *LegacyMount, force bool) error {
+	if mount.method == "systemd" && !force {
+		unit, err := getMountUnitName(config, mount.target)
+		if err != nil {
+			return err
+		}
+		_, err = callSshCommand(config, "systemctl stop %s", unit)
+		return err
+	}
+
+	serialized_options := ""
+	if force {
+		serialized_options = " -f"
+	}
+	_, err := callSshCommand(config, "umount%s %s", serialized_options, shellescape.Quote(mount.target))
+	return err
+}
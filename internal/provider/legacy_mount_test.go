@@ -0,0 +1,73 @@
+package provider
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestRenderFstabEntry verifies that legacy mounts are rendered as fstab
+// entries, and parsed back into the same mount.
+func TestRenderFstabEntry(t *testing.T) {
+	cases := map[string]*LegacyMount{
+		"tank/root /mnt/root zfs defaults 0 0": {
+			target:  "/mnt/root",
+			options: []string{},
+			method:  "fstab",
+		},
+		"tank/root /mnt/root zfs noatime,nofail 0 0": {
+			target:  "/mnt/root",
+			options: []string{"noatime", "nofail"},
+			method:  "fstab",
+		},
+		"tank/root /mnt/My\\040Files zfs defaults 0 0": {
+			target:  "/mnt/My Files",
+			options: []string{},
+			method:  "fstab",
+		},
+	}
+
+	for expected, mount := range cases {
+		if rendered := renderFstabEntry("tank/root", mount); rendered != expected {
+			t.Fatalf("expected %s, got %s", expected, rendered)
+		}
+		if parsed := parseFstabEntry(expected); !reflect.DeepEqual(parsed, mount) {
+			t.Fatalf("parsing %s: expected %v, got %v", expected, mount, parsed)
+		}
+	}
+
+	if parsed := parseFstabEntry(""); parsed != nil {
+		t.Fatalf("expected missing entry to parse as nil, got %v", parsed)
+	}
+}
+
+// TestRenderMountUnit verifies that legacy mounts are rendered as systemd
+// mount units, and parsed back into the same mount.
+func TestRenderMountUnit(t *testing.T) {
+	mount := &LegacyMount{
+		target:  "/var/lib/containers",
+		options: []string{"noatime"},
+		method:  "systemd",
+	}
+
+	if parsed := parseMountUnit(renderMountUnit("tank/containers", mount)); !reflect.DeepEqual(parsed, mount) {
+		t.Fatalf("expected %v, got %v", mount, parsed)
+	}
+
+	if parsed := parseMountUnit(""); parsed != nil {
+		t.Fatalf("expected missing unit to parse as nil, got %v", parsed)
+	}
+}
+
+// TestLockedFstabScript verifies that fstab edits hold a lock on the fstab
+// and don't share a fixed temporary file between filesystems.
+func TestLockedFstabScript(t *testing.T) {
+	script := lockedFstabScript(removeFstabEntryScript("tank/root"))
+
+	if !strings.HasPrefix(script, "(flock 9 && ") || !strings.HasSuffix(script, ") 9</etc/fstab") {
+		t.Fatalf("expected the script to hold a lock on the fstab, got %s", script)
+	}
+	if strings.Contains(script, "/etc/fstab.tmp") || !strings.Contains(script, "mktemp") {
+		t.Fatalf("expected the script to use a unique temporary file, got %s", script)
+	}
+}
@@ -0,0 +1,634 @@
+package provider
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/alessio/shellescape"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+type PropertyKind string
+
+const (
+	PropertyBool   PropertyKind = "bool"
+	PropertyEnum   PropertyKind = "enum"
+	PropertySize   PropertyKind = "size"
+	PropertyNumber PropertyKind = "number"
+	PropertyString PropertyKind = "string"
+)
+
+// PropertyTarget is a bitmask of the kinds of resources a property can be set on.
+type PropertyTarget int
+
+const (
+	TargetFilesystem PropertyTarget = 1 << iota
+	TargetVolume
+	TargetPool
+
+	TargetDataset = TargetFilesystem | TargetVolume
+)
+
+func (t PropertyTarget) String() string {
+	switch t {
+	case TargetFilesystem:
+		return "filesystem"
+	case TargetVolume:
+		return "volume"
+	default:
+		return "zpool"
+	}
+}
+
+type PropertyDefinition struct {
+	kind PropertyKind
+	// Allowed values of enums, or keywords accepted in place of a size or number, such as "none".
+	values []string
+	// Used instead of values for enums with too many variants to list, such as the compression levels.
+	pattern    *regexp.Regexp
+	target     PropertyTarget
+	readOnly   bool
+	createOnly bool
+	// Non-inheritable properties are reset by setting them to resetValue instead of using `zfs inherit`.
+	inheritable bool
+	resetValue  string
+}
+
+func readOnlyProperty(target PropertyTarget) PropertyDefinition {
+	return PropertyDefinition{kind: PropertyString, target: target, readOnly: true}
+}
+
+func boolProperty(target PropertyTarget) PropertyDefinition {
+	return PropertyDefinition{kind: PropertyBool, target: target, inheritable: true}
+}
+
+func enumProperty(target PropertyTarget, values ...string) PropertyDefinition {
+	return PropertyDefinition{kind: PropertyEnum, values: values, target: target, inheritable: true}
+}
+
+func stringProperty(target PropertyTarget) PropertyDefinition {
+	return PropertyDefinition{kind: PropertyString, target: target, inheritable: true}
+}
+
+// datasetPropertyCatalog describes the native properties of filesystems and volumes, see man zfsprops.
+var datasetPropertyCatalog = map[string]PropertyDefinition{
+	"available":            readOnlyProperty(TargetDataset),
+	"clones":               readOnlyProperty(TargetDataset),
+	"compressratio":        readOnlyProperty(TargetDataset),
+	"createtxg":            readOnlyProperty(TargetDataset),
+	"creation":             readOnlyProperty(TargetDataset),
+	"defer_destroy":        readOnlyProperty(TargetDataset),
+	"encryptionroot":       readOnlyProperty(TargetDataset),
+	"filesystem_count":     readOnlyProperty(TargetDataset),
+	"guid":                 readOnlyProperty(TargetDataset),
+	"keystatus":            readOnlyProperty(TargetDataset),
+	"logicalreferenced":    readOnlyProperty(TargetDataset),
+	"logicalused":          readOnlyProperty(TargetDataset),
+	"mounted":              readOnlyProperty(TargetFilesystem),
+	"objsetid":             readOnlyProperty(TargetDataset),
+	"origin":               readOnlyProperty(TargetDataset),
+	"receive_resume_token": readOnlyProperty(TargetDataset),
+	"redact_snaps":         readOnlyProperty(TargetDataset),
+	"refcompressratio":     readOnlyProperty(TargetDataset),
+	"referenced":           readOnlyProperty(TargetDataset),
+	"snapshot_count":       readOnlyProperty(TargetDataset),
+	"snapshots_changed":    readOnlyProperty(TargetDataset),
+	"type":                 readOnlyProperty(TargetDataset),
+	"used":                 readOnlyProperty(TargetDataset),
+	"usedbychildren":       readOnlyProperty(TargetDataset),
+	"usedbydataset":        readOnlyProperty(TargetDataset),
+	"usedbyrefreservation": readOnlyProperty(TargetDataset),
+	"usedbysnapshots":      readOnlyProperty(TargetDataset),
+	"userrefs":             readOnlyProperty(TargetDataset),
+	"written":              readOnlyProperty(TargetDataset),
+
+	"aclinherit":  enumProperty(TargetFilesystem, "discard", "noallow", "restricted", "passthrough", "passthrough-x"),
+	"aclmode":     enumProperty(TargetFilesystem, "discard", "groupmask", "passthrough", "restricted"),
+	"acltype":     enumProperty(TargetFilesystem, "off", "nfsv4", "posix"), // zfs reads the noacl and posixacl aliases back as off and posix
+	"atime":       boolProperty(TargetFilesystem),
+	"canmount":    {kind: PropertyEnum, values: []string{"on", "off", "noauto"}, target: TargetFilesystem, resetValue: "on"},
+	"checksum":    enumProperty(TargetDataset, "on", "off", "fletcher2", "fletcher4", "sha256", "noparity", "sha512", "skein", "edonr", "blake3"),
+	"compression": {kind: PropertyEnum, pattern: regexp.MustCompile(`^(on|off|lzjb|lz4|zle|gzip(-[1-9])?|zstd(-([1-9]|1[0-9]))?|zstd-fast(-([1-9]|10|[2-9]0|100|500|1000))?)$`), target: TargetDataset, inheritable: true},
+	"context":     stringProperty(TargetDataset),
+	"copies":      enumProperty(TargetDataset, "1", "2", "3"),
+	"dedup":       {kind: PropertyEnum, pattern: regexp.MustCompile(`^(on|off|verify|(sha256|sha512|skein|edonr|blake3)(,verify)?)$`), target: TargetDataset, inheritable: true},
+	"defcontext":  stringProperty(TargetDataset),
+	"devices":     boolProperty(TargetFilesystem),
+	"direct":      enumProperty(TargetDataset, "disabled", "standard", "always"),
+	"dnodesize":   enumProperty(TargetFilesystem, "legacy", "auto", "1k", "2k", "4k", "8k", "16k"),
+	"encryption": {
+		kind:       PropertyEnum,
+		values:     []string{"off", "on", "aes-128-ccm", "aes-192-ccm", "aes-256-ccm", "aes-128-gcm", "aes-192-gcm", "aes-256-gcm"},
+		target:     TargetDataset,
+		createOnly: true,
+	},
+	"exec":                 boolProperty(TargetFilesystem),
+	"filesystem_limit":     {kind: PropertyNumber, values: []string{"none"}, target: TargetFilesystem, resetValue: "none"},
+	"fscontext":            stringProperty(TargetDataset),
+	"keyformat":            {kind: PropertyEnum, values: []string{"raw", "hex", "passphrase"}, target: TargetDataset, createOnly: true},
+	"keylocation":          {kind: PropertyString, target: TargetDataset},
+	"logbias":              enumProperty(TargetDataset, "latency", "throughput"),
+	"longname":             boolProperty(TargetFilesystem),
+	"mlslabel":             stringProperty(TargetDataset),
+	"mountpoint":           {kind: PropertyString, pattern: regexp.MustCompile(`^(/.*|none|legacy)$`), target: TargetFilesystem, inheritable: true},
+	"nbmand":               boolProperty(TargetFilesystem),
+	"normalization":        {kind: PropertyEnum, values: []string{"none", "formC", "formD", "formKC", "formKD"}, target: TargetFilesystem, createOnly: true},
+	"overlay":              boolProperty(TargetFilesystem),
+	"pbkdf2iters":          {kind: PropertyNumber, target: TargetDataset, createOnly: true},
+	"prefetch":             enumProperty(TargetDataset, "none", "metadata", "all"),
+	"primarycache":         enumProperty(TargetDataset, "all", "none", "metadata"),
+	"quota":                {kind: PropertySize, values: []string{"none"}, target: TargetFilesystem, resetValue: "none"},
+	"readonly":             boolProperty(TargetDataset),
+	"recordsize":           {kind: PropertySize, target: TargetFilesystem, inheritable: true},
+	"redundant_metadata":   enumProperty(TargetDataset, "all", "most", "some", "none"),
+	"refquota":             {kind: PropertySize, values: []string{"none"}, target: TargetFilesystem, resetValue: "none"},
+	"refreservation":       {kind: PropertySize, values: []string{"none", "auto"}, target: TargetDataset, resetValue: "none"},
+	"relatime":             boolProperty(TargetFilesystem),
+	"reservation":          {kind: PropertySize, values: []string{"none"}, target: TargetDataset, resetValue: "none"},
+	"rootcontext":          stringProperty(TargetDataset),
+	"secondarycache":       enumProperty(TargetDataset, "all", "none", "metadata"),
+	"setuid":               boolProperty(TargetFilesystem),
+	"sharenfs":             stringProperty(TargetFilesystem),
+	"sharesmb":             stringProperty(TargetFilesystem),
+	"snapdev":              enumProperty(TargetVolume, "hidden", "visible"),
+	"snapdir":              enumProperty(TargetFilesystem, "disabled", "hidden", "visible"),
+	"snapshot_limit":       {kind: PropertyNumber, values: []string{"none"}, target: TargetDataset, resetValue: "none"},
+	"special_small_blocks": {kind: PropertySize, target: TargetFilesystem, inheritable: true},
+	"sync":                 enumProperty(TargetDataset, "standard", "always", "disabled"),
+	"utf8only":             {kind: PropertyBool, target: TargetFilesystem, createOnly: true},
+	"casesensitivity":      {kind: PropertyEnum, values: []string{"sensitive", "insensitive", "mixed"}, target: TargetFilesystem, createOnly: true},
+	"version":              {kind: PropertyNumber, values: []string{"current"}, target: TargetFilesystem},
+	"volblocksize":         {kind: PropertySize, target: TargetVolume, createOnly: true},
+	"volmode":              enumProperty(TargetVolume, "default", "full", "geom", "dev", "none"),
+	"volsize":              {kind: PropertySize, target: TargetVolume},
+	"volthreading":         boolProperty(TargetVolume),
+	"vscan":                boolProperty(TargetFilesystem),
+	"xattr":                enumProperty(TargetFilesystem, "on", "off", "dir", "sa"),
+	"zoned":                boolProperty(TargetFilesystem),
+}
+
+// poolPropertyCatalog describes the native properties of zpools, see man zpoolprops. None of these can be inherited.
+var poolPropertyCatalog = map[string]PropertyDefinition{
+	"allocated":        readOnlyProperty(TargetPool),
+	"bcloneratio":      readOnlyProperty(TargetPool),
+	"bclonesaved":      readOnlyProperty(TargetPool),
+	"bcloneused":       readOnlyProperty(TargetPool),
+	"capacity":         readOnlyProperty(TargetPool),
+	"checkpoint":       readOnlyProperty(TargetPool),
+	"dedup_table_size": readOnlyProperty(TargetPool),
+	"dedupratio":       readOnlyProperty(TargetPool),
+	"expandsize":       readOnlyProperty(TargetPool),
+	"fragmentation":    readOnlyProperty(TargetPool),
+	"free":             readOnlyProperty(TargetPool),
+	"freeing":          readOnlyProperty(TargetPool),
+	"guid":             readOnlyProperty(TargetPool),
+	"health":           readOnlyProperty(TargetPool),
+	"leaked":           readOnlyProperty(TargetPool),
+	"load_guid":        readOnlyProperty(TargetPool),
+	"size":             readOnlyProperty(TargetPool),
+
+	"altroot":           {kind: PropertyString, target: TargetPool, createOnly: true},
+	"ashift":            {kind: PropertyEnum, values: []string{"0", "9", "10", "11", "12", "13", "14", "15", "16"}, target: TargetPool, createOnly: true},
+	"autoexpand":        {kind: PropertyBool, target: TargetPool},
+	"autoreplace":       {kind: PropertyBool, target: TargetPool},
+	"autotrim":          {kind: PropertyBool, target: TargetPool},
+	"bootfs":            {kind: PropertyString, target: TargetPool},
+	"cachefile":         {kind: PropertyString, target: TargetPool},
+	"comment":           {kind: PropertyString, target: TargetPool},
+	"compatibility":     {kind: PropertyString, target: TargetPool},
+	"dedup_table_quota": {kind: PropertySize, values: []string{"auto", "none"}, target: TargetPool},
+	"delegation":        {kind: PropertyBool, target: TargetPool},
+	"failmode":          {kind: PropertyEnum, values: []string{"wait", "continue", "panic"}, target: TargetPool},
+	"listsnapshots":     {kind: PropertyBool, target: TargetPool},
+	"multihost":         {kind: PropertyBool, target: TargetPool},
+	"readonly":          {kind: PropertyBool, target: TargetPool, createOnly: true},
+	"version":           {kind: PropertyNumber, target: TargetPool},
+}
+
+var quotaPropertyPattern = regexp.MustCompile(`^(user|group|project)(obj)?(quota|used)@.+$`)
+
+// lookupProperty finds the definition of a native property. User properties, features and the per user/group/project
+// quotas are not part of the catalog, and are described on the fly.
+func lookupProperty(name string, target PropertyTarget) (PropertyDefinition, bool) {
+	if target == TargetPool {
+		if strings.HasPrefix(name, "feature@") {
+			return PropertyDefinition{kind: PropertyEnum, values: []string{"enabled", "disabled"}, target: TargetPool}, true
+		}
+		if definition, ok := poolPropertyCatalog[name]; ok {
+			return definition, true
+		}
+		// Everything else is applied to the root filesystem of the pool.
+		target = TargetFilesystem
+	}
+
+	if strings.Contains(name, ":") {
+		return PropertyDefinition{kind: PropertyString, target: TargetDataset | TargetPool, inheritable: true}, true
+	}
+	if match := quotaPropertyPattern.FindStringSubmatch(name); match != nil {
+		if match[3] == "used" {
+			return readOnlyProperty(TargetFilesystem), true
+		}
+		return PropertyDefinition{kind: PropertySize, values: []string{"none"}, target: TargetFilesystem, resetValue: "none"}, true
+	}
+
+	definition, ok := datasetPropertyCatalog[name]
+	return definition, ok
+}
+
+// validatePropertyTarget checks that a known property can be set on the target, returning its definition. Unknown
+// properties are left for zfs to validate, as they may have been added in a newer version of OpenZFS, and are dealt
+// with by validatePropertyName instead.
+func validatePropertyTarget(name string, target PropertyTarget) (PropertyDefinition, bool, error) {
+	definition, ok := lookupProperty(name, target)
+	if !ok {
+		if isPoolProperty(name) {
+			return definition, false, fmt.Errorf("property %s cannot be set on a %s", name, target)
+		}
+		return definition, false, nil
+	}
+
+	if definition.readOnly {
+		return definition, true, fmt.Errorf("property %s is read-only", name)
+	}
+	// Properties other than the pool properties are applied to the root filesystem of a pool.
+	effectiveTarget := target
+	if target == TargetPool && !isPoolProperty(name) {
+		effectiveTarget = TargetFilesystem
+	}
+	if definition.target&effectiveTarget == 0 {
+		return definition, true, fmt.Errorf("property %s cannot be set on a %s", name, effectiveTarget)
+	}
+	return definition, true, nil
+}
+
+// validateProperty validates the value of known properties, see validatePropertyTarget.
+func validateProperty(name string, value string, target PropertyTarget) error {
+	definition, ok, err := validatePropertyTarget(name, target)
+	if !ok || err != nil {
+		return err
+	}
+
+	for _, keyword := range definition.values {
+		if value == keyword {
+			return nil
+		}
+	}
+	if definition.pattern != nil {
+		if !definition.pattern.MatchString(value) {
+			return fmt.Errorf("invalid value %q for property %s", value, name)
+		}
+		return nil
+	}
+
+	switch definition.kind {
+	case PropertyBool:
+		if value != "on" && value != "off" {
+			return fmt.Errorf("invalid value %q for property %s, expected on or off", value, name)
+		}
+	case PropertyEnum:
+		return fmt.Errorf("invalid value %q for property %s, expected one of %s", value, name, strings.Join(definition.values, ", "))
+	case PropertyNumber:
+		if _, err := strconv.ParseUint(value, 10, 64); err != nil {
+			return fmt.Errorf("invalid value %q for property %s, expected a number", value, name)
+		}
+	case PropertySize:
+		size, err := parseSize(value)
+		if err != nil {
+			return fmt.Errorf("invalid value %q for property %s, expected a size such as 128K", value, name)
+		}
+		// Block sizes must be powers of two.
+		if (name == "recordsize" || name == "volblocksize" || name == "special_small_blocks") && size&(size-1) != 0 {
+			return fmt.Errorf("invalid value %q for property %s, expected a power of two", value, name)
+		}
+	}
+
+	return nil
+}
+
+// validatePropertyName refuses property names which aren't in the catalog but are close to a known property, as they
+// are most likely misspelled. Other unknown names are only warned about, as they may be newer native properties.
+func validatePropertyName(value interface{}, k string) ([]string, []error) {
+	name := value.(string)
+	// On a pool, the dataset properties apply to the root filesystem, so this finds both kinds of property.
+	if _, ok := lookupProperty(name, TargetPool); ok {
+		return nil, nil
+	}
+	if suggestion := suggestPropertyName(name, TargetPool); suggestion != "" {
+		return nil, []error{fmt.Errorf("unknown property %s, did you mean %s?", name, suggestion)}
+	}
+	return []string{fmt.Sprintf("unknown property %s, it is passed to zfs without validation", name)}, nil
+}
+
+// suggestPropertyName returns the closest known property name to a misspelled one, if there is one that is close.
+func suggestPropertyName(name string, target PropertyTarget) string {
+	candidates := mapKeys(datasetPropertyCatalog)
+	if target == TargetPool {
+		candidates = append(candidates, mapKeys(poolPropertyCatalog)...)
+	}
+	sort.Strings(candidates)
+
+	best, bestDistance := "", 3
+	for _, candidate := range candidates {
+		if distance := editDistance(name, candidate); distance < bestDistance {
+			best, bestDistance = candidate, distance
+		}
+	}
+	return best
+}
+
+func editDistance(a string, b string) int {
+	previous := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current := make([]int, len(b)+1)
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous = current
+	}
+	return previous[len(b)]
+}
+
+// customizePropertyDiff validates the property blocks at plan time, and forces a new resource when properties which
+// can only be set at creation time are changed.
+func customizePropertyDiff(d *schema.ResourceDiff, target PropertyTarget) error {
+	if !d.NewValueKnown("property") {
+		return nil
+	}
+
+	old, new := d.GetChange("property")
+	oldProperties := parsePropertyBlocks(old.(*schema.Set).List())
+	newProperties := parsePropertyBlocks(new.(*schema.Set).List())
+
+	// Values which won't be known until apply can only be checked against the target.
+	unknownValues := make(map[string]bool)
+	if rawConfig := d.GetRawConfig(); !rawConfig.IsNull() {
+		if blocks := rawConfig.GetAttr("property"); blocks.IsKnown() && !blocks.IsNull() {
+			for it := blocks.ElementIterator(); it.Next(); {
+				_, block := it.Element()
+				if name := block.GetAttr("name"); name.IsKnown() && !name.IsNull() && !block.GetAttr("value").IsWhollyKnown() {
+					unknownValues[name.AsString()] = true
+				}
+			}
+		}
+	}
+
+	for name, value := range newProperties {
+		if unknownValues[name] {
+			if _, _, err := validatePropertyTarget(name, target); err != nil {
+				return err
+			}
+		} else if err := validateProperty(name, value, target); err != nil {
+			return err
+		}
+	}
+
+	// Nothing to recreate when creating the resource.
+	if d.Id() == "" {
+		return nil
+	}
+
+	for name, value := range newProperties {
+		if definition, _ := lookupProperty(name, target); definition.createOnly && oldProperties[name] != value {
+			return d.ForceNew("property")
+		}
+	}
+	for name := range oldProperties {
+		if definition, _ := lookupProperty(name, target); definition.createOnly {
+			if _, ok := newProperties[name]; !ok {
+				return d.ForceNew("property")
+			}
+		}
+	}
+
+	return nil
+}
+
+// datasetAttributes are the properties exposed as top-level attributes on filesystems and volumes, rather than having
+// to be set through property blocks.
+var datasetAttributes = []string{
+	"acltype",
+	"atime",
+	"canmount",
+	"compression",
+	"dedup",
+	"quota",
+	"readonly",
+	"recordsize",
+	"refquota",
+	"refreservation",
+	"reservation",
+	"sync",
+	"xattr",
+}
+
+func datasetAttributeSchema(name string, target PropertyTarget) *schema.Schema {
+	definition := datasetPropertyCatalog[name]
+
+	attribute := &schema.Schema{
+		Description: fmt.Sprintf("Value of the `%s` property. Left as is if not set.", name),
+		Optional:    true,
+		Computed:    true,
+	}
+	if definition.inheritable {
+		attribute.Description = fmt.Sprintf("Value of the `%s` property. Inherited from the parent dataset if not set when the dataset is created, and left as is when removed from the configuration.", name)
+	}
+
+	if definition.kind == PropertyBool {
+		attribute.Type = schema.TypeBool
+		return attribute
+	}
+
+	attribute.Type = schema.TypeString
+	attribute.ValidateDiagFunc = validation.ToDiagFunc(func(value interface{}, k string) ([]string, []error) {
+		if err := validateProperty(name, value.(string), target); err != nil {
+			return nil, []error{err}
+		}
+		return nil, nil
+	})
+	if definition.kind == PropertySize {
+		attribute.DiffSuppressFunc = suppressEquivalentSizeDiff
+	}
+	if name == "refreservation" {
+		attribute.DiffSuppressFunc = suppressAutoRefreservationDiff
+	}
+	return attribute
+}
+
+func datasetAttributesFor(target PropertyTarget) []string {
+	names := make([]string, 0)
+	for _, name := range datasetAttributes {
+		if datasetPropertyCatalog[name].target&target != 0 {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// getDatasetAttributeProperties returns the properties of the dataset attributes set in the configuration. Attributes
+// which are not set are computed, so their value in the plan is whatever was last read.
+func getDatasetAttributeProperties(d *schema.ResourceData, target PropertyTarget) map[string]string {
+	properties := make(map[string]string)
+	config := d.GetRawConfig()
+	for _, name := range datasetAttributesFor(target) {
+		if config.IsNull() || config.GetAttr(name).IsNull() {
+			continue
+		}
+		switch value := d.Get(name).(type) {
+		case bool:
+			properties[name] = "off"
+			if value {
+				properties[name] = "on"
+			}
+		case string:
+			properties[name] = value
+		}
+	}
+	return properties
+}
+
+func updateDatasetAttributesInState(d *schema.ResourceData, properties map[string]Property, target PropertyTarget) error {
+	for _, name := range datasetAttributesFor(target) {
+		property, ok := properties[name]
+		if !ok {
+			continue
+		}
+
+		var value interface{} = property.value
+		if datasetPropertyCatalog[name].kind == PropertyBool {
+			value = property.value == "on"
+		}
+		if err := d.Set(name, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getIgnoredDatasetAttributes returns the dataset attributes which should be kept out of the property blocks, which is
+// all of them except the ones still being managed through a property block.
+func getIgnoredDatasetAttributes(d *schema.ResourceData, target PropertyTarget) []string {
+	defined := make(map[string]bool)
+	for _, name := range getPropertyNames(d) {
+		defined[name] = true
+	}
+
+	ignored := make([]string, 0)
+	for _, name := range datasetAttributesFor(target) {
+		if !defined[name] {
+			ignored = append(ignored, name)
+		}
+	}
+	return ignored
+}
+
+var userPropertyNamePattern = regexp.MustCompile(`^[a-z0-9:._-]+$`)
+
+// validateUserPropertyName checks a user property name against the rules in man zfsprops.
+func validateUserPropertyName(name string) error {
+	if !strings.Contains(name, ":") {
+		return fmt.Errorf("user property %s must contain a colon, such as com.example:%s", name, name)
+	}
+	if strings.HasPrefix(name, "-") {
+		return fmt.Errorf("user property %s cannot begin with a dash", name)
+	}
+	if len(name) > 256 {
+		return fmt.Errorf("user property %s is longer than 256 characters", name)
+	}
+	if !userPropertyNamePattern.MatchString(name) {
+		return fmt.Errorf("user property %s may only contain lowercase letters, numbers, and the characters : . _ -", name)
+	}
+	return nil
+}
+
+func validateUserProperties(value interface{}, k string) ([]string, []error) {
+	errs := make([]error, 0)
+	for name, value := range value.(map[string]interface{}) {
+		if err := validateUserPropertyName(name); err != nil {
+			errs = append(errs, err)
+		}
+		if value, ok := value.(string); ok && len(value) > 8192 {
+			errs = append(errs, fmt.Errorf("the value of user property %s is longer than 8192 bytes", name))
+		}
+	}
+	return nil, errs
+}
+
+func getUserProperties(d *schema.ResourceData) map[string]string {
+	properties := make(map[string]string)
+	for name, value := range d.Get("user_properties").(map[string]interface{}) {
+		properties[name] = value.(string)
+	}
+	return properties
+}
+
+// removeUserProperties inherits the user properties which were removed from user_properties. Setting the others is
+// left to applyPropertyDiff.
+func removeUserProperties(config *Config, d *schema.ResourceData, targetName string) error {
+	old, new := d.GetChange("user_properties")
+	for name := range old.(map[string]interface{}) {
+		if _, ok := new.(map[string]interface{})[name]; ok {
+			continue
+		}
+		if _, err := callSshCommand(config, "zfs inherit %s %s", shellescape.Quote(name), targetName); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func updateUserPropertiesInState(d *schema.ResourceData, properties map[string]Property) error {
+	if _, ok := d.GetOk("user_properties_mode"); !ok {
+		if err := d.Set("user_properties_mode", "declared"); err != nil {
+			return err
+		}
+	}
+	exact := d.Get("user_properties_mode").(string) == "exact"
+
+	declared := getUserProperties(d)
+	defined := make(map[string]bool)
+	for _, name := range getPropertyNames(d) {
+		defined[name] = true
+	}
+
+	userProperties := make(map[string]string)
+	for name, property := range properties {
+		if !strings.Contains(name, ":") {
+			continue
+		}
+		if _, ok := declared[name]; ok || (exact && property.source == SourceLocal && !defined[name]) {
+			userProperties[name] = property.value
+		}
+	}
+	return d.Set("user_properties", userProperties)
+}
+
+// getIgnoredUserProperties returns the user properties which should be kept out of the property blocks because they
+// are managed through user_properties, which in exact mode is all of them not defined in a property block.
+func getIgnoredUserProperties(d *schema.ResourceData, properties map[string]Property) []string {
+	exact := d.Get("user_properties_mode").(string) == "exact"
+	declared := getUserProperties(d)
+	defined := make(map[string]bool)
+	for _, name := range getPropertyNames(d) {
+		defined[name] = true
+	}
+
+	ignored := make([]string, 0)
+	for name := range properties {
+		if _, ok := declared[name]; ok || (exact && strings.Contains(name, ":") && !defined[name]) {
+			ignored = append(ignored, name)
+		}
+	}
+	return ignored
+}
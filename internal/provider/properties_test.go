@@ -0,0 +1,265 @@
+package provider
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// TestValidateProperty_Valid verifies that well-formed native, user and
+// quota properties are accepted on the resources they apply to.
+func TestValidateProperty_Valid(t *testing.T) {
+	cases := []struct {
+		name   string
+		value  string
+		target PropertyTarget
+	}{
+		{"compression", "zstd-3", TargetFilesystem},
+		{"compression", "lz4", TargetVolume},
+		{"recordsize", "1M", TargetFilesystem},
+		{"atime", "off", TargetFilesystem},
+		{"quota", "none", TargetFilesystem},
+		{"quota", "10G", TargetFilesystem},
+		{"volblocksize", "16K", TargetVolume},
+		{"com.example:backup", "daily", TargetFilesystem},
+		{"userquota@alice", "5G", TargetFilesystem},
+		{"ashift", "12", TargetPool},
+		{"autotrim", "on", TargetPool},
+		{"feature@zstd_compress", "enabled", TargetPool},
+		// Dataset properties on a pool apply to its root filesystem.
+		{"compression", "lz4", TargetPool},
+		{"mountpoint", "/srv/data", TargetFilesystem},
+		{"dedup", "sha256,verify", TargetVolume},
+		{"volthreading", "off", TargetVolume},
+		{"dedup_table_quota", "auto", TargetPool},
+		{"dedup_table_quota", "10G", TargetPool},
+		// Properties from newer versions of OpenZFS are left for zfs to validate.
+		{"some_future_property", "on", TargetFilesystem},
+	}
+
+	for _, c := range cases {
+		if err := validateProperty(c.name, c.value, c.target); err != nil {
+			t.Fatalf("unexpected error validating %s=%s on a %s: %v", c.name, c.value, c.target, err)
+		}
+	}
+}
+
+// TestValidateProperty_Invalid verifies that typos, read-only properties,
+// bad values and properties set on the wrong kind of resource are refused.
+func TestValidateProperty_Invalid(t *testing.T) {
+	cases := []struct {
+		name   string
+		value  string
+		target PropertyTarget
+	}{
+		{"recordsize", "3K", TargetFilesystem},
+		{"recordsize", "big", TargetFilesystem},
+		{"atime", "yes", TargetFilesystem},
+		{"compression", "zstd-20", TargetFilesystem},
+		{"used", "1G", TargetFilesystem},
+		{"volblocksize", "16K", TargetFilesystem},
+		{"recordsize", "128K", TargetVolume},
+		{"ashift", "12", TargetFilesystem},
+		{"ashift", "17", TargetPool},
+		{"mountpoint", "srv/data", TargetFilesystem},
+		{"filesystem_limit", "lots", TargetFilesystem},
+		// Aliases would be read back as their canonical value, and never converge.
+		{"acltype", "posixacl", TargetFilesystem},
+	}
+
+	for _, c := range cases {
+		if err := validateProperty(c.name, c.value, c.target); err == nil {
+			t.Fatalf("expected an error validating %s=%s on a %s", c.name, c.value, c.target)
+		}
+	}
+}
+
+// TestValidatePropertyName_Suggestion verifies that misspelled property
+// names are refused with a suggestion for the property that was likely
+// intended, while other unknown names are only warned about.
+func TestValidatePropertyName_Suggestion(t *testing.T) {
+	warnings, errs := validatePropertyName("compresion", "property.0.name")
+	if len(warnings) != 0 || len(errs) != 1 || errs[0].Error() != "unknown property compresion, did you mean compression?" {
+		t.Fatalf("unexpected warnings %v and errors %v", warnings, errs)
+	}
+
+	warnings, errs = validatePropertyName("some_future_property", "property.0.name")
+	if len(errs) != 0 || len(warnings) != 1 {
+		t.Fatalf("unexpected warnings %v and errors %v", warnings, errs)
+	}
+
+	for _, name := range []string{"compression", "volthreading", "bcloneused", "feature@zstd_compress", "com.example:backup"} {
+		if warnings, errs := validatePropertyName(name, "property.0.name"); len(warnings) != 0 || len(errs) != 0 {
+			t.Fatalf("unexpected warnings %v and errors %v for %s", warnings, errs, name)
+		}
+	}
+}
+
+// TestValidatePropertyTarget verifies that properties whose value is not
+// known yet are still checked against the resource they are set on.
+func TestValidatePropertyTarget(t *testing.T) {
+	if _, _, err := validatePropertyTarget("volsize", TargetFilesystem); err == nil {
+		t.Fatalf("expected an error setting volsize on a filesystem")
+	}
+	if _, _, err := validatePropertyTarget("ashift", TargetVolume); err == nil {
+		t.Fatalf("expected an error setting ashift on a volume")
+	}
+	if _, known, err := validatePropertyTarget("compression", TargetPool); err != nil || !known {
+		t.Fatalf("expected compression to be known and settable on a pool, got %v", err)
+	}
+	if _, known, err := validatePropertyTarget("some_future_property", TargetFilesystem); err != nil || known {
+		t.Fatalf("expected some_future_property to be unknown, got %v", err)
+	}
+}
+
+// TestGetResetCommand verifies that non-inheritable properties are reset
+// by setting them, and that properties without a default are left alone.
+func TestGetResetCommand(t *testing.T) {
+	cases := map[string]string{
+		"compression":        "zfs inherit -S compression",
+		"com.example:backup": "zfs inherit -S com.example:backup",
+		"quota":              "zfs set quota=none",
+		"userquota@alice":    "zfs set userquota@alice=none",
+		"canmount":           "zfs set canmount=on",
+	}
+	for property, expected := range cases {
+		if command, ok := getResetCommand(property); !ok || command != expected {
+			t.Fatalf("expected %q for %s, got %q", expected, property, command)
+		}
+	}
+
+	for _, property := range []string{"ashift", "keylocation"} {
+		if _, ok := getResetCommand(property); ok {
+			t.Fatalf("expected %s not to be reset", property)
+		}
+	}
+}
+
+// TestDatasetAttributesFor verifies that filesystem-only properties are not
+// exposed as attributes on volumes.
+func TestDatasetAttributesFor(t *testing.T) {
+	if len(datasetAttributesFor(TargetFilesystem)) != len(datasetAttributes) {
+		t.Fatalf("expected every dataset attribute on filesystems, got %v", datasetAttributesFor(TargetFilesystem))
+	}
+
+	volume := datasetAttributesFor(TargetVolume)
+	for _, name := range volume {
+		if name == "recordsize" || name == "quota" || name == "atime" || name == "canmount" {
+			t.Fatalf("filesystem-only attribute %s exposed on volumes", name)
+		}
+	}
+	if len(volume) != 6 {
+		t.Fatalf("unexpected volume attributes: %v", volume)
+	}
+}
+
+// TestDatasetAttributeSchema verifies that attributes are computed from the
+// dataset, typed after the property and validated against the catalog.
+func TestDatasetAttributeSchema(t *testing.T) {
+	atime := datasetAttributeSchema("atime", TargetFilesystem)
+	if atime.Type != schema.TypeBool || !atime.Optional || !atime.Computed {
+		t.Fatalf("expected atime to be an optional, computed bool")
+	}
+
+	recordsize := datasetAttributeSchema("recordsize", TargetFilesystem)
+	if recordsize.DiffSuppressFunc == nil {
+		t.Fatalf("expected recordsize to suppress equivalent sizes")
+	}
+	if diags := recordsize.ValidateDiagFunc("3K", nil); !diags.HasError() {
+		t.Fatalf("expected recordsize 3K to be refused")
+	}
+	if diags := recordsize.ValidateDiagFunc("1M", nil); diags.HasError() {
+		t.Fatalf("unexpected error validating recordsize 1M: %v", diags)
+	}
+}
+
+// TestValidateUserPropertyName verifies the naming rules for user
+// properties described in man zfsprops.
+func TestValidateUserPropertyName(t *testing.T) {
+	for _, name := range []string{"com.example:owner", "syncoid:sync", "org.znapzend:dst_a_plan", "a:b_c-d"} {
+		if err := validateUserPropertyName(name); err != nil {
+			t.Fatalf("unexpected error validating %s: %v", name, err)
+		}
+	}
+
+	invalid := []string{
+		"owner",
+		"-com.example:owner",
+		"com.example:Owner",
+		"com.example:owner name",
+		"com.example:owner+group",
+		"com.example:" + strings.Repeat("a", 250),
+	}
+	for _, name := range invalid {
+		if err := validateUserPropertyName(name); err == nil {
+			t.Fatalf("expected an error validating %s", name)
+		}
+	}
+}
+
+// TestUserProperties_Modes verifies which user properties are tracked in
+// user_properties and kept out of the property blocks for each mode.
+func TestUserProperties_Modes(t *testing.T) {
+	properties := map[string]Property{
+		"compression":       {source: SourceLocal, value: "lz4"},
+		"com.example:owner": {source: SourceLocal, value: "alice"},
+		"com.example:team":  {source: SourceLocal, value: "storage"},
+		"syncoid:sync":      {source: SourceInherited, value: "false"},
+	}
+
+	for mode, expected := range map[string]map[string]string{
+		"declared": {"com.example:owner": "alice"},
+		"exact":    {"com.example:owner": "alice", "com.example:team": "storage"},
+	} {
+		d := schema.TestResourceDataRaw(t, resourceFilesystem().Schema, map[string]interface{}{
+			"name":                 "tank/data",
+			"user_properties":      map[string]interface{}{"com.example:owner": "bob"},
+			"user_properties_mode": mode,
+		})
+
+		ignored := getIgnoredUserProperties(d, properties)
+		if err := updateUserPropertiesInState(d, properties); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		actual := getUserProperties(d)
+		if len(actual) != len(expected) {
+			t.Fatalf("%s: expected %v, got %v", mode, expected, actual)
+		}
+		for name, value := range expected {
+			if actual[name] != value {
+				t.Fatalf("%s: expected %v, got %v", mode, expected, actual)
+			}
+		}
+
+		for _, name := range ignored {
+			if name == "compression" {
+				t.Fatalf("%s: native property %s should not be ignored", mode, name)
+			}
+		}
+		if mode == "exact" && len(ignored) != 3 {
+			t.Fatalf("%s: expected all user properties to be ignored, got %v", mode, ignored)
+		}
+	}
+}
+
+// TestFlattenPropertySources verifies that the source of every property is
+// exposed, along with where inherited properties are inherited from.
+func TestFlattenPropertySources(t *testing.T) {
+	properties := map[string]Property{
+		"compression": {source: SourceInherited, value: "lz4", inheritedFrom: "tank"},
+		"quota":       {source: SourceLocal, value: "10G"},
+		"atime":       {source: SourceDefault, value: "on"},
+	}
+
+	sources := flattenPropertySources(properties)
+	if sources["compression"] != "inherited" || sources["quota"] != "local" || sources["atime"] != "default" {
+		t.Fatalf("unexpected sources: %v", sources)
+	}
+
+	inheritedFrom := flattenInheritedFrom(properties)
+	if len(inheritedFrom) != 1 || inheritedFrom["compression"] != "tank" {
+		t.Fatalf("unexpected inherited_from: %v", inheritedFrom)
+	}
+}
@@ -83,9 +83,16 @@ func New(version string) func() *schema.Provider {
 				"zfs_volume":     dataSourceVolume(),
 			},
 			ResourcesMap: map[string]*schema.Resource{
-				"zfs_filesystem": resourceFilesystem(),
-				"zfs_volume":     resourceVolume(),
-				"zfs_pool":       resourcePool(),
+				"zfs_filesystem":      resourceFilesystem(),
+				"zfs_volume":          resourceVolume(),
+				"zfs_pool":            resourcePool(),
+				"zfs_pool_import":     resourcePoolImport(),
+				"zfs_pool_scrub":      resourcePoolScrub(),
+				"zfs_pool_checkpoint": resourcePoolCheckpoint(),
+				"zfs_pool_split":      resourcePoolSplit(),
+				"zfs_rollback":        resourceRollback(),
+				"zfs_snapshot_hold":   resourceSnapshotHold(),
+				"zfs_bookmark":        resourceBookmark(),
 			},
 		}
 
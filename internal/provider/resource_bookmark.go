@@ -0,0 +1,186 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+var bookmarkNamePattern = regexp.MustCompile(`^[^@#]+#[^@#]+$`)
+
+func resourceBookmark() *schema.Resource {
+	return &schema.Resource{
+		// This description is used by the documentation generator and the language server.
+		Description: "Manages a bookmark of a snapshot, created using `zfs bookmark`. Bookmarks can be used as the source of incremental sends without keeping the snapshot around.",
+
+		CreateContext: resourceBookmarkCreate,
+		ReadContext:   resourceBookmarkRead,
+		DeleteContext: resourceBookmarkDelete,
+
+		Importer: &schema.ResourceImporter{
+			StateContext: resourceBookmarkImport,
+		},
+
+		Schema: map[string]*schema.Schema{
+			"name": {
+				Description:      "Name of the bookmark, such as `tank/data#baseline`.",
+				Type:             schema.TypeString,
+				Required:         true,
+				ForceNew:         true,
+				ValidateDiagFunc: validation.ToDiagFunc(validation.StringMatch(bookmarkNamePattern, "must be a bookmark name of the form dataset#bookmark")),
+			},
+			"snapshot": {
+				Description:      "Name of the snapshot to bookmark, such as `tank/data@baseline`. The snapshot can be destroyed once bookmarked.",
+				Type:             schema.TypeString,
+				Required:         true,
+				ForceNew:         true,
+				ValidateDiagFunc: validation.ToDiagFunc(validation.StringMatch(snapshotNamePattern, "must be a snapshot name of the form dataset@snapshot")),
+				// The snapshot of an imported bookmark is unknown if it has since been destroyed.
+				DiffSuppressFunc: func(k, old, new string, d *schema.ResourceData) bool {
+					return old == "" && d.Id() != ""
+				},
+			},
+			"guid": {
+				Description: "Guid of the bookmark, which is the same as the guid of the snapshot it was created from.",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+			"createtxg": {
+				Description: "Transaction group in which the bookmarked snapshot was created.",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+			"creation": {
+				Description: "Time at which the bookmarked snapshot was created.",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+		},
+	}
+}
+
+func describeBookmark(config *Config, bookmarkName string) (map[string]Property, error) {
+	properties := make(map[string]Property)
+	if err := readSomeProperties(config, "zfs", bookmarkName, "guid,createtxg,creation", properties); err != nil {
+		return nil, err
+	}
+	return properties, nil
+}
+
+func resourceBookmarkCreate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	config := meta.(*Config)
+	bookmarkName := d.Get("name").(string)
+
+	if err := createBookmark(config, d.Get("snapshot").(string), bookmarkName); err != nil {
+		return diag.FromErr(err)
+	}
+
+	properties, err := describeBookmark(config, bookmarkName)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	guid := properties["guid"].value
+	log.Printf("[DEBUG] committing guid: %s", guid)
+	d.SetId(guid)
+
+	return resourceBookmarkRead(ctx, d, meta)
+}
+
+func resourceBookmarkRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	config := meta.(*Config)
+
+	bookmarkName := d.Get("name").(string)
+	properties, err := describeBookmark(config, bookmarkName)
+	found, err := matchBookmark(bookmarkName, d.Id(), properties, err)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	if !found {
+		log.Printf("[DEBUG] bookmark %s identified by guid %s no longer exists", bookmarkName, d.Id())
+		d.SetId("")
+		return diags
+	}
+
+	if d.Get("snapshot").(string) == "" {
+		if snapshotName, err := getSnapshotNameByGuid(config, d.Id()); err == nil {
+			if err := d.Set("snapshot", *snapshotName); err != nil {
+				return diag.FromErr(err)
+			}
+		}
+	}
+
+	for _, name := range []string{"guid", "createtxg", "creation"} {
+		if err := d.Set(name, properties[name].value); err != nil {
+			return diag.FromErr(err)
+		}
+	}
+
+	return diags
+}
+
+// matchBookmark checks that the bookmark read by name is the one identified by the guid. Every bookmark of a snapshot
+// shares its guid, so a missing bookmark is never looked up by guid, as that could adopt a bookmark made by another tool.
+func matchBookmark(bookmarkName string, guid string, properties map[string]Property, err error) (bool, error) {
+	if _, ok := err.(*DatasetError); ok {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	if actual := properties["guid"].value; actual != guid {
+		return false, fmt.Errorf("the bookmark %s has guid %s instead of %s. It was likely recreated on the server outside of terraform", bookmarkName, actual, guid)
+	}
+	return true, nil
+}
+
+func resourceBookmarkDelete(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	config := meta.(*Config)
+
+	if err := destroyBookmark(config, d.Get("name").(string)); err != nil {
+		return diag.FromErr(err)
+	}
+
+	d.SetId("")
+
+	return diags
+}
+
+// resourceBookmarkImport imports bookmarks by either name or guid. Importing by guid only works if the snapshot has
+// been bookmarked once, as the bookmarks of a snapshot share its guid.
+func resourceBookmarkImport(ctx context.Context, d *schema.ResourceData, meta interface{}) ([]*schema.ResourceData, error) {
+	config := meta.(*Config)
+
+	bookmarkName := d.Id()
+	if !strings.Contains(bookmarkName, "#") {
+		names, err := getBookmarkNamesByGuid(config, d.Id())
+		if err != nil {
+			return nil, err
+		}
+		if len(names) != 1 {
+			return nil, fmt.Errorf("found %d bookmarks with guid %s, import the bookmark by name instead", len(names), d.Id())
+		}
+		bookmarkName = names[0]
+	}
+
+	properties, err := describeBookmark(config, bookmarkName)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := d.Set("name", bookmarkName); err != nil {
+		return nil, err
+	}
+	d.SetId(properties["guid"].value)
+
+	return []*schema.ResourceData{d}, nil
+}
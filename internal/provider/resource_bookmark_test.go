@@ -0,0 +1,58 @@
+package provider
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// TestBookmarkNamePattern verifies that only full bookmark names are
+// accepted, and that snapshot names are refused.
+func TestBookmarkNamePattern(t *testing.T) {
+	for name, valid := range map[string]bool{
+		"tank/data#baseline": true,
+		"tank#baseline":      true,
+		"tank/data@baseline": false,
+		"tank/data":          false,
+		"#baseline":          false,
+		"tank/data#":         false,
+		"tank/data#a#b":      false,
+	} {
+		if bookmarkNamePattern.MatchString(name) != valid {
+			t.Fatalf("expected %s to be valid: %v", name, valid)
+		}
+	}
+}
+
+// TestMatchBookmark verifies that a missing bookmark is reported as gone
+// rather than looked up by guid, and that a bookmark recreated under the
+// same name is refused instead of adopted.
+func TestMatchBookmark(t *testing.T) {
+	properties := map[string]Property{"guid": {value: "1234"}}
+
+	if found, err := matchBookmark("tank/data#a", "1234", properties, nil); !found || err != nil {
+		t.Fatalf("expected the bookmark to be found, got %v, %v", found, err)
+	}
+	if found, err := matchBookmark("tank/data#a", "1234", nil, &DatasetError{errmsg: "dataset does not exist"}); found || err != nil {
+		t.Fatalf("expected a missing bookmark to be reported as gone, got %v, %v", found, err)
+	}
+	if found, err := matchBookmark("tank/data#a", "5678", properties, nil); found || err == nil {
+		t.Fatalf("expected an error for a bookmark with a different guid, got %v, %v", found, err)
+	}
+	if found, err := matchBookmark("tank/data#a", "1234", nil, &SshConnectError{inner: errors.New("command timed out")}); found || err == nil {
+		t.Fatalf("expected connection errors to be returned, got %v, %v", found, err)
+	}
+}
+
+// TestFindNamesByGuid verifies that every bookmark sharing the guid of a
+// snapshot is found, so that imports by guid can refuse ambiguous matches.
+func TestFindNamesByGuid(t *testing.T) {
+	stdout := "tank/data#replication\t1234\ntank/data#baseline\t1234\ntank/data#other\t5678"
+
+	if names := findNamesByGuid(stdout, "1234"); !reflect.DeepEqual(names, []string{"tank/data#replication", "tank/data#baseline"}) {
+		t.Fatalf("unexpected names: %v", names)
+	}
+	if names := findNamesByGuid(stdout, "9999"); len(names) != 0 {
+		t.Fatalf("expected no names, got %v", names)
+	}
+}
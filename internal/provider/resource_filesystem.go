@@ -4,9 +4,12 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"regexp"
+	"strings"
 
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
 )
 
 func resourceFilesystem() *schema.Resource {
@@ -19,6 +22,8 @@ func resourceFilesystem() *schema.Resource {
 		UpdateContext: resourceFilesystemUpdate,
 		DeleteContext: resourceFilesystemDelete,
 
+		CustomizeDiff: resourceFilesystemCustomizeDiff,
+
 		Importer: &schema.ResourceImporter{
 			StateContext: schema.ImportStatePassthroughContext,
 		},
@@ -36,6 +41,19 @@ func resourceFilesystem() *schema.Resource {
 				Optional:    true,
 				Default:     "none",
 			},
+			"mounted": {
+				Description: "Whether the filesystem should be mounted. Left as is if not set, which for filesystems with `canmount = \"noauto\"` means they are not mounted. Cannot be `true` with `canmount = \"off\"`, or with `mountpoint = \"none\"` or `\"legacy\"` without a `legacy_mount`. The ownership, mode and acls of the mountpoint are only applied and read while the filesystem is mounted.",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Computed:    true,
+			},
+			"legacy_mount": &legacyMountSchema,
+			"force_unmount": {
+				Description: "Forcefully unmount the filesystem when `mounted` is set to `false`, even if it is in use. Defaults to `false`",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+			},
 			"owner": {
 				Description:   "Set owner of the mountpoint. Must be a valid username",
 				Type:          schema.TypeString,
@@ -64,10 +82,88 @@ func resourceFilesystem() *schema.Resource {
 				ConflictsWith: []string{"group"},
 				RequiredWith:  []string{"mountpoint"},
 			},
-			"property":       &propertySchema,
-			"property_mode":  &propertyModeSchema,
-			"properties":     &propertiesSchema,
-			"raw_properties": &rawPropertiesSchema,
+			"mode": {
+				Description:      "Set the permissions of the mountpoint, in octal such as `0755`.",
+				Type:             schema.TypeString,
+				Optional:         true,
+				RequiredWith:     []string{"mountpoint"},
+				ValidateDiagFunc: validation.ToDiagFunc(validation.StringMatch(filemodePattern, "must be an octal file mode such as 0755")),
+				DiffSuppressFunc: suppressEquivalentFilemodeDiff,
+			},
+			"recursive_ownership": {
+				Description: "Apply the owner and group to everything below the mountpoint as well. Defaults to `false`",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+			},
+			"acl": {
+				Description:   "POSIX acl entries of the mountpoint, managed using `setfacl`. Requires `acltype = \"posix\"`. Extended entries not listed here are removed, but removing all of the entries from the configuration leaves the acl of the mountpoint as it is.",
+				Type:          schema.TypeSet,
+				Optional:      true,
+				ConflictsWith: []string{"nfs4_acl"},
+				RequiredWith:  []string{"mountpoint"},
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"type": {
+							Description:      "Type of the entry. One of `user`, `group`, `mask` or `other`.",
+							Type:             schema.TypeString,
+							Required:         true,
+							ValidateDiagFunc: validation.ToDiagFunc(validation.StringInSlice([]string{"user", "group", "mask", "other"}, false)),
+						},
+						"name": {
+							Description: "Name of the user or group the entry applies to. Leave empty for the owning user or group.",
+							Type:        schema.TypeString,
+							Optional:    true,
+							Default:     "",
+						},
+						"permissions": {
+							Description:      "Permissions granted by the entry, such as `rwx` or `r-x`.",
+							Type:             schema.TypeString,
+							Required:         true,
+							ValidateDiagFunc: validation.ToDiagFunc(validation.StringMatch(regexp.MustCompile(`^[r-][w-][x-]$`), "must be in the form rwx, with - for permissions not granted")),
+						},
+						"default": {
+							Description: "Make this a default entry, inherited by files and directories created below the mountpoint. Defaults to `false`",
+							Type:        schema.TypeBool,
+							Optional:    true,
+							Default:     false,
+						},
+					},
+				},
+			},
+			"nfs_share":      &nfsShareSchema,
+			"smb_share":      &smbShareSchema,
+			"nfs_exports":    &nfsExportsSchema,
+			"smb_usershares": &smbUsersharesSchema,
+			"nfs4_acl": {
+				Description:   "NFSv4 access control entries of the mountpoint such as `A::OWNER@:rwaDxtTcCy`, managed using `nfs4_setfacl`. Requires `acltype = \"nfsv4\"`. Removing all of the entries from the configuration leaves the acl of the mountpoint as it is.",
+				Type:          schema.TypeList,
+				Optional:      true,
+				ConflictsWith: []string{"acl"},
+				RequiredWith:  []string{"mountpoint"},
+				Elem:          &schema.Schema{Type: schema.TypeString},
+			},
+			"acltype":              datasetAttributeSchema("acltype", TargetFilesystem),
+			"atime":                datasetAttributeSchema("atime", TargetFilesystem),
+			"canmount":             datasetAttributeSchema("canmount", TargetFilesystem),
+			"compression":          datasetAttributeSchema("compression", TargetFilesystem),
+			"dedup":                datasetAttributeSchema("dedup", TargetFilesystem),
+			"quota":                datasetAttributeSchema("quota", TargetFilesystem),
+			"readonly":             datasetAttributeSchema("readonly", TargetFilesystem),
+			"recordsize":           datasetAttributeSchema("recordsize", TargetFilesystem),
+			"refquota":             datasetAttributeSchema("refquota", TargetFilesystem),
+			"refreservation":       datasetAttributeSchema("refreservation", TargetFilesystem),
+			"reservation":          datasetAttributeSchema("reservation", TargetFilesystem),
+			"sync":                 datasetAttributeSchema("sync", TargetFilesystem),
+			"xattr":                datasetAttributeSchema("xattr", TargetFilesystem),
+			"property":             &propertySchema,
+			"property_mode":        &propertyModeSchema,
+			"user_properties":      &userPropertiesSchema,
+			"user_properties_mode": &userPropertiesModeSchema,
+			"properties":           &propertiesSchema,
+			"raw_properties":       &rawPropertiesSchema,
+			"property_sources":     &propertySourcesSchema,
+			"inherited_from":       &inheritedFromSchema,
 		},
 	}
 }
@@ -103,6 +199,24 @@ func resourceFilesystemCreate(ctx context.Context, d *schema.ResourceData, meta
 
 	mountpoint := d.Get("mountpoint").(string)
 	properties := parsePropertyBlocks(d.Get("property").(*schema.Set).List())
+	for name, value := range mergeProperties(getDatasetAttributeProperties(d, TargetFilesystem), getUserProperties(d), getShareProperties(d)) {
+		if _, ok := properties[name]; ok {
+			return diag.FromErr(fmt.Errorf("don't set '%s' as a property block, use the dedicated attribute instead", name))
+		}
+		properties[name] = value
+	}
+	// The acls are applied after creating the filesystem, so an inherited acltype which doesn't support them is caught
+	// before leaving a half-created filesystem behind.
+	if _, ok := properties["acltype"]; !ok && strings.Contains(filesystemName, "/") && (hasPosixAcl(d) || hasNfs4Acl(d)) {
+		parent := make(map[string]Property)
+		if err := readSomeProperties(config, "zfs", filesystemName[:strings.LastIndex(filesystemName, "/")], "acltype", parent); err != nil {
+			return diag.FromErr(err)
+		}
+		if err := validateAclType(parent["acltype"].value, hasPosixAcl(d), hasNfs4Acl(d)); err != nil {
+			return diag.FromErr(err)
+		}
+	}
+
 	filesystem, err = createDataset(config, &CreateDataset{
 		dsType:     FilesystemType,
 		name:       filesystemName,
@@ -119,32 +233,39 @@ func resourceFilesystemCreate(ctx context.Context, d *schema.ResourceData, meta
 	log.Printf("[DEBUG] committing guid: %s", filesystem.guid)
 	d.SetId(filesystem.guid)
 
-	if mountpoint != "none" && mountpoint != "legacy" {
-		if uid, ok := d.GetOk("uid"); ok {
-			if _, err = callSshCommand(config, "chown '%d' '%s'", uid.(int), mountpoint); err != nil {
-				return diag.FromErr(err)
-			}
+	if mount := expandLegacyMount(d); mount != nil {
+		if err := installLegacyMount(config, filesystemName, mount); err != nil {
+			return diag.FromErr(err)
 		}
+	}
 
-		if gid, ok := d.GetOk("gid"); ok {
-			if _, err = callSshCommand(config, "chgrp '%d' '%s'", gid.(int), mountpoint); err != nil {
-				return diag.FromErr(err)
-			}
+	mounted, err := applyMount(config, d, filesystemName, filesystem.mounted == "yes")
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	// Ownership, mode and acls are set on the root of the filesystem, so they are applied before unmounting it.
+	if mounted {
+		mountPath := getMountPath(d, mountpoint)
+		if err := applyMountpointOwnership(config, d, mountPath, true); err != nil {
+			return diag.FromErr(err)
 		}
 
-		if owner, ok := d.GetOk("owner"); ok {
-			if _, err = callSshCommand(config, "chown '%s' '%s'", owner.(string), mountpoint); err != nil {
-				return diag.FromErr(err)
-			}
+		if err := applyMountpointAcl(config, d, mountPath, true); err != nil {
+			return diag.FromErr(err)
 		}
 
-		if group, ok := d.GetOk("group"); ok {
-			if _, err = callSshCommand(config, "chgrp '%s' '%s'", group.(string), mountpoint); err != nil {
+		if len(getShareProperties(d)) > 0 {
+			if err := shareFilesystem(config, filesystemName); err != nil {
 				return diag.FromErr(err)
 			}
 		}
 	}
 
+	if err := applyUnmount(config, d, filesystemName, mounted); err != nil {
+		return diag.FromErr(err)
+	}
+
 	return diags
 }
 
@@ -177,9 +298,26 @@ func resourceFilesystemRead(ctx context.Context, d *schema.ResourceData, meta in
 		return diag.FromErr(err)
 	}
 
-	if filesystem.mountpoint != "none" && filesystem.mountpoint != "legacy" {
+	if err = d.Set("mounted", filesystem.mounted == "yes"); err != nil {
+		return diag.FromErr(err)
+	}
+
+	if mount := expandLegacyMount(d); mount != nil {
+		actual, err := readLegacyMount(config, filesystemName, mount)
+		if err != nil {
+			return diag.FromErr(err)
+		}
+		if err = d.Set("legacy_mount", flattenLegacyMount(actual)); err != nil {
+			return diag.FromErr(err)
+		}
+	}
+
+	// Without the filesystem mounted, the mountpoint is a directory of the parent filesystem, so the ownership, mode
+	// and acls are left as they are in the state.
+	mountPath := getMountPath(d, filesystem.mountpoint)
+	if mountPath != "none" && mountPath != "legacy" && filesystem.mounted == "yes" {
 		log.Println("[DEBUG] Fetching filesystem mountpoint ownership information")
-		ownership, err := getFileOwnership(config, filesystem.mountpoint)
+		ownership, err := getFileOwnership(config, mountPath)
 		if err != nil {
 			return diag.FromErr(err)
 		}
@@ -208,6 +346,32 @@ func resourceFilesystemRead(ctx context.Context, d *schema.ResourceData, meta in
 				return diag.FromErr(err)
 			}
 		}
+
+		if _, ok := d.GetOk("mode"); ok {
+			if err = d.Set("mode", ownership.mode); err != nil {
+				return diag.FromErr(err)
+			}
+		}
+
+		if configured, ok := d.GetOk("acl"); ok {
+			entries, err := getFileAcl(config, mountPath)
+			if err != nil {
+				return diag.FromErr(err)
+			}
+			if err = d.Set("acl", flattenAclEntries(entries, expandAclEntries(configured.(*schema.Set).List()))); err != nil {
+				return diag.FromErr(err)
+			}
+		}
+
+		if _, ok := d.GetOk("nfs4_acl"); ok {
+			entries, err := getNfs4Acl(config, mountPath)
+			if err != nil {
+				return diag.FromErr(err)
+			}
+			if err = d.Set("nfs4_acl", entries); err != nil {
+				return diag.FromErr(err)
+			}
+		}
 	} else {
 		if err = d.Set("owner", nil); err != nil {
 			return diag.FromErr(err)
@@ -224,9 +388,51 @@ func resourceFilesystemRead(ctx context.Context, d *schema.ResourceData, meta in
 		if err = d.Set("uid", nil); err != nil {
 			return diag.FromErr(err)
 		}
+
+		if err = d.Set("mode", nil); err != nil {
+			return diag.FromErr(err)
+		}
 	}
 
-	if err := updatePropertiesInState(d, filesystem.properties, []string{"mountpoint"}); err != nil {
+	if err := updateDatasetAttributesInState(d, filesystem.properties, TargetFilesystem); err != nil {
+		return diag.FromErr(err)
+	}
+
+	// Properties managed through dedicated attributes are kept out of the property blocks.
+	ignoredProperties := []string{"mountpoint"}
+	ignoredProperties = append(ignoredProperties, getIgnoredDatasetAttributes(d, TargetFilesystem)...)
+	ignoredProperties = append(ignoredProperties, getIgnoredUserProperties(d, filesystem.properties)...)
+	ignoredProperties = append(ignoredProperties, getIgnoredShareProperties(d)...)
+
+	if err := updateSharesInState(d, filesystem.properties); err != nil {
+		return diag.FromErr(err)
+	}
+
+	exports := make([]NfsExport, 0)
+	if _, ok := d.GetOk("nfs_share"); ok && mountPath != "none" && mountPath != "legacy" {
+		if exports, err = getNfsExports(config, mountPath); err != nil {
+			return diag.FromErr(err)
+		}
+	}
+	if err := d.Set("nfs_exports", flattenNfsExports(exports)); err != nil {
+		return diag.FromErr(err)
+	}
+
+	usershares := make([]SmbUsershare, 0)
+	if _, ok := d.GetOk("smb_share"); ok && mountPath != "none" && mountPath != "legacy" {
+		if usershares, err = getSmbUsershares(config, mountPath); err != nil {
+			return diag.FromErr(err)
+		}
+	}
+	if err := d.Set("smb_usershares", flattenSmbUsershares(usershares)); err != nil {
+		return diag.FromErr(err)
+	}
+
+	if err := updateUserPropertiesInState(d, filesystem.properties); err != nil {
+		return diag.FromErr(err)
+	}
+
+	if err := updatePropertiesInState(d, filesystem.properties, ignoredProperties); err != nil {
 		return diag.FromErr(err)
 	}
 
@@ -242,8 +448,9 @@ func resourceFilesystemUpdate(ctx context.Context, d *schema.ResourceData, meta
 	}
 
 	filesystemName := d.Get("name").(string)
+	renamed := filesystemName != *old_name
 	// Rename the filesystem
-	if filesystemName != *old_name {
+	if renamed {
 		if err := renameDataset(config, *old_name, filesystemName); err != nil {
 			return diag.FromErr(err)
 		}
@@ -255,37 +462,78 @@ func resourceFilesystemUpdate(ctx context.Context, d *schema.ResourceData, meta
 	}
 
 	overrideProperties := map[string]string{"mountpoint": d.Get("mountpoint").(string)}
+	for name, value := range mergeProperties(getDatasetAttributeProperties(d, TargetFilesystem), getUserProperties(d), getShareProperties(d)) {
+		overrideProperties[name] = value
+	}
+
+	if err := removeUserProperties(config, d, filesystemName); err != nil {
+		return diag.FromErr(err)
+	}
+
+	if err := removeShareProperties(config, d, filesystemName); err != nil {
+		return diag.FromErr(err)
+	}
+
 	err = applyPropertyDiff(config, d, filesystemName, filesystem.properties, overrideProperties)
 	if err != nil {
 		return diag.FromErr(err)
 	}
 
-	if mountpoint, ok := d.GetOk("mountpoint"); ok {
-		if uid, ok := d.GetOk("uid"); ok && d.HasChange("uid") {
-			if _, err = callSshCommand(config, "chown '%d' '%s'", uid.(int), mountpoint.(string)); err != nil {
+	// The fstab entry and mount unit refer to the filesystem by name, so they are reinstalled when it is renamed.
+	if d.HasChange("legacy_mount") || renamed {
+		old, new := d.GetChange("legacy_mount")
+		if old := old.([]interface{}); len(old) > 0 && old[0] != nil {
+			if err := removeLegacyMount(config, *old_name, expandLegacyMountBlock(old[0].(map[string]interface{}))); err != nil {
 				return diag.FromErr(err)
 			}
 		}
-
-		if gid, ok := d.GetOk("gid"); ok && d.HasChange("gid") {
-			if _, err = callSshCommand(config, "chgrp '%d' '%s'", gid.(int), mountpoint.(string)); err != nil {
+		if new := new.([]interface{}); len(new) > 0 && new[0] != nil {
+			if err := installLegacyMount(config, filesystemName, expandLegacyMountBlock(new[0].(map[string]interface{}))); err != nil {
 				return diag.FromErr(err)
 			}
 		}
+	}
 
-		if owner, ok := d.GetOk("owner"); ok && d.HasChange("owner") {
-			if _, err = callSshCommand(config, "chown '%s' '%s'", owner.(string), mountpoint.(string)); err != nil {
-				return diag.FromErr(err)
-			}
+	// Changing the mountpoint remounts the filesystem, and reinstalling a mount unit stops it, so the mount state is
+	// checked afterwards.
+	mounted, err := getDatasetMounted(config, filesystemName)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	mountStateChanged := d.HasChanges("mounted", "mountpoint", "legacy_mount") || renamed
+	if mountStateChanged {
+		if mounted, err = applyMount(config, d, filesystemName, mounted); err != nil {
+			return diag.FromErr(err)
+		}
+	}
+
+	// Ownership, mode and acls are set on the root of the filesystem, so they can only be applied while it is mounted.
+	// A new mountpoint is a new directory, which won't have the desired ownership and mode yet, and neither will a
+	// filesystem which was unmounted when they were last changed.
+	if mounted {
+		all := d.HasChanges("mountpoint", "legacy_mount", "mounted")
+		mountPath := getMountPath(d, d.Get("mountpoint").(string))
+		if err := applyMountpointOwnership(config, d, mountPath, all); err != nil {
+			return diag.FromErr(err)
+		}
+
+		if err := applyMountpointAcl(config, d, mountPath, all); err != nil {
+			return diag.FromErr(err)
 		}
 
-		if group, ok := d.GetOk("group"); ok && d.HasChange("group") {
-			if _, err = callSshCommand(config, "chgrp '%s' '%s'", group.(string), mountpoint.(string)); err != nil {
+		if d.HasChanges("nfs_share", "smb_share") && len(getShareProperties(d)) > 0 {
+			if err := shareFilesystem(config, filesystemName); err != nil {
 				return diag.FromErr(err)
 			}
 		}
 	}
 
+	if mountStateChanged {
+		if err := applyUnmount(config, d, filesystemName, mounted); err != nil {
+			return diag.FromErr(err)
+		}
+	}
+
 	return resourceFilesystemRead(ctx, d, meta)
 }
 
@@ -294,6 +542,12 @@ func resourceFilesystemDelete(ctx context.Context, d *schema.ResourceData, meta
 	config := meta.(*Config)
 	filesystemName := d.Get("name").(string)
 
+	if mount := expandLegacyMount(d); mount != nil {
+		if err := removeLegacyMount(config, filesystemName, mount); err != nil {
+			return diag.FromErr(err)
+		}
+	}
+
 	if err := destroyDataset(config, filesystemName); err != nil {
 		return diag.FromErr(err)
 	}
@@ -302,3 +556,244 @@ func resourceFilesystemDelete(ctx context.Context, d *schema.ResourceData, meta
 
 	return diags
 }
+
+// applyMountpointOwnership sets the owner, group and mode of the mountpoint. Unless all is set, only the attributes
+// which changed are applied.
+func applyMountpointOwnership(config *Config, d *schema.ResourceData, mountpoint string, all bool) error {
+	if mountpoint == "none" || mountpoint == "legacy" {
+		return nil
+	}
+
+	recursive := ""
+	if d.Get("recursive_ownership").(bool) {
+		recursive = "-R "
+	}
+	// Switching to recursive ownership has to reapply the ownership to everything below the mountpoint.
+	all = all || (recursive != "" && d.HasChange("recursive_ownership"))
+
+	if uid, ok := d.GetOk("uid"); ok && (all || d.HasChange("uid")) {
+		if _, err := callSshCommand(config, "chown %s'%d' '%s'", recursive, uid.(int), mountpoint); err != nil {
+			return err
+		}
+	}
+
+	if gid, ok := d.GetOk("gid"); ok && (all || d.HasChange("gid")) {
+		if _, err := callSshCommand(config, "chgrp %s'%d' '%s'", recursive, gid.(int), mountpoint); err != nil {
+			return err
+		}
+	}
+
+	if owner, ok := d.GetOk("owner"); ok && (all || d.HasChange("owner")) {
+		if _, err := callSshCommand(config, "chown %s'%s' '%s'", recursive, owner.(string), mountpoint); err != nil {
+			return err
+		}
+	}
+
+	if group, ok := d.GetOk("group"); ok && (all || d.HasChange("group")) {
+		if _, err := callSshCommand(config, "chgrp %s'%s' '%s'", recursive, group.(string), mountpoint); err != nil {
+			return err
+		}
+	}
+
+	if mode, ok := d.GetOk("mode"); ok && (all || d.HasChange("mode")) {
+		if _, err := callSshCommand(config, "chmod '%s' '%s'", mode.(string), mountpoint); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func applyMountpointAcl(config *Config, d *schema.ResourceData, mountpoint string, all bool) error {
+	if mountpoint == "none" || mountpoint == "legacy" {
+		return nil
+	}
+
+	// Like nfs4_acl, removing the last acl block leaves the acl of the mountpoint as it is.
+	if acl := d.Get("acl").(*schema.Set); acl.Len() > 0 && (all || d.HasChange("acl")) {
+		if err := setFileAcl(config, mountpoint, expandAclEntries(acl.List())); err != nil {
+			return err
+		}
+	}
+
+	if all || d.HasChange("nfs4_acl") {
+		if acl, ok := d.GetOk("nfs4_acl"); ok {
+			entries := make([]string, 0)
+			for _, entry := range acl.([]interface{}) {
+				entries = append(entries, entry.(string))
+			}
+			if err := setNfs4Acl(config, mountpoint, entries); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func expandAclEntries(blocks []interface{}) []AclEntry {
+	entries := make([]AclEntry, 0)
+	for _, block := range blocks {
+		block := block.(map[string]interface{})
+		entries = append(entries, AclEntry{
+			tag:         block["type"].(string),
+			qualifier:   block["name"].(string),
+			permissions: block["permissions"].(string),
+			isDefault:   block["default"].(bool),
+		})
+	}
+	return entries
+}
+
+// flattenAclEntries turns the acl entries of a file into acl blocks. Every file has entries for the owner, group and
+// other, and setfacl adds masks (and default entries for them) as needed, so these are only tracked when configured.
+func flattenAclEntries(entries []AclEntry, configured []AclEntry) []interface{} {
+	tracked := make(map[string]bool)
+	for _, entry := range configured {
+		tracked[AclEntry{tag: entry.tag, qualifier: entry.qualifier, isDefault: entry.isDefault}.String()] = true
+	}
+
+	blocks := make([]interface{}, 0)
+	for _, entry := range entries {
+		implicit := entry.qualifier == "" || entry.tag == "mask"
+		if implicit && !tracked[AclEntry{tag: entry.tag, qualifier: entry.qualifier, isDefault: entry.isDefault}.String()] {
+			continue
+		}
+		blocks = append(blocks, map[string]interface{}{
+			"type":        entry.tag,
+			"name":        entry.qualifier,
+			"permissions": entry.permissions,
+			"default":     entry.isDefault,
+		})
+	}
+	return blocks
+}
+
+// getMountedState returns the legacy mount of the filesystem, if any, and whether it has to be mounted or unmounted.
+// The filesystem is only mounted or unmounted if mounted is set and differs from the actual state, except for
+// filesystems with a legacy_mount, which are mounted unless mounted is set to false.
+func getMountedState(d *schema.ResourceData, mounted bool) (*LegacyMount, bool, bool) {
+	mount := expandLegacyMount(d)
+	if d.Get("mountpoint").(string) != "legacy" {
+		mount = nil
+	}
+
+	var configured *bool
+	if rawConfig := d.GetRawConfig(); !rawConfig.IsNull() && !rawConfig.GetAttr("mounted").IsNull() {
+		desired := d.Get("mounted").(bool)
+		configured = &desired
+	}
+
+	mountNeeded, unmountNeeded := decideMountedState(configured, mount != nil, mounted)
+	return mount, mountNeeded, unmountNeeded
+}
+
+// applyMount mounts the filesystem if needed, see getMountedState, returning whether it is mounted afterwards.
+func applyMount(config *Config, d *schema.ResourceData, filesystemName string, mounted bool) (bool, error) {
+	mount, mountNeeded, _ := getMountedState(d, mounted)
+	if !mountNeeded {
+		return mounted, nil
+	}
+
+	log.Printf("[DEBUG] mounting filesystem: %s", filesystemName)
+	if mount != nil {
+		return true, mountLegacy(config, filesystemName, mount)
+	}
+	return true, mountDataset(config, filesystemName)
+}
+
+// applyUnmount unmounts the filesystem if needed, see getMountedState.
+func applyUnmount(config *Config, d *schema.ResourceData, filesystemName string, mounted bool) error {
+	mount, _, unmountNeeded := getMountedState(d, mounted)
+	if !unmountNeeded {
+		return nil
+	}
+
+	log.Printf("[DEBUG] unmounting filesystem: %s", filesystemName)
+	if mount != nil {
+		return unmountLegacy(config, mount, d.Get("force_unmount").(bool))
+	}
+	return unmountDataset(config, filesystemName, d.Get("force_unmount").(bool))
+}
+
+// decideMountedState returns whether the filesystem has to be mounted or unmounted to match the configured state,
+// which is nil if mounted is not set.
+func decideMountedState(configured *bool, legacyMount bool, mounted bool) (bool, bool) {
+	desired := legacyMount
+	if configured != nil {
+		desired = *configured
+	} else if !legacyMount {
+		return false, false
+	}
+	return desired && !mounted, !desired && mounted
+}
+
+// validateMountedState refuses mounted = true on filesystems which can never be mounted.
+func validateMountedState(filesystemName string, mountpoint string, canmount string, legacyMount bool) error {
+	if mountpoint == "none" || (mountpoint == "legacy" && !legacyMount) {
+		return fmt.Errorf("filesystem %s cannot be mounted with mountpoint %s", filesystemName, mountpoint)
+	}
+	if canmount == "off" {
+		return fmt.Errorf("filesystem %s cannot be mounted with canmount off", filesystemName)
+	}
+	return nil
+}
+
+type aclGetter interface {
+	Get(key string) interface{}
+}
+
+func hasPosixAcl(d aclGetter) bool {
+	return d.Get("acl").(*schema.Set).Len() > 0
+}
+
+func hasNfs4Acl(d aclGetter) bool {
+	return len(d.Get("nfs4_acl").([]interface{})) > 0
+}
+
+// validateAclType refuses acl entries which the acltype of the filesystem doesn't support.
+func validateAclType(acltype string, posixAcl bool, nfs4Acl bool) error {
+	if posixAcl && acltype != "posix" && acltype != "posixacl" {
+		return fmt.Errorf("acl requires acltype = \"posix\", but acltype is %s", acltype)
+	}
+	if nfs4Acl && acltype != "nfsv4" {
+		return fmt.Errorf("nfs4_acl requires acltype = \"nfsv4\", but acltype is %s", acltype)
+	}
+	return nil
+}
+
+func resourceFilesystemCustomizeDiff(ctx context.Context, d *schema.ResourceDiff, meta interface{}) error {
+	mountpoint := d.Get("mountpoint").(string)
+	_, legacyMount := d.GetOk("legacy_mount")
+	if legacyMount && d.NewValueKnown("mountpoint") && mountpoint != "legacy" {
+		return fmt.Errorf("legacy_mount requires mountpoint = \"legacy\" on filesystem %s", d.Get("name").(string))
+	}
+
+	// mounted is computed, so it is only checked when it is actually configured.
+	if rawConfig := d.GetRawConfig(); !rawConfig.IsNull() {
+		if mounted := rawConfig.GetAttr("mounted"); mounted.IsKnown() && !mounted.IsNull() && mounted.True() {
+			canmount := ""
+			if d.NewValueKnown("canmount") {
+				canmount = d.Get("canmount").(string)
+			}
+			if err := validateMountedState(d.Get("name").(string), mountpoint, canmount, legacyMount); err != nil {
+				return err
+			}
+		}
+	}
+
+	// An acltype inherited by a new filesystem isn't known until it is created, which is checked by the create instead.
+	if hasPosixAcl(d) || hasNfs4Acl(d) {
+		if acltype, ok := parsePropertyBlocks(d.Get("property").(*schema.Set).List())["acltype"]; ok && d.NewValueKnown("property") {
+			if err := validateAclType(acltype, hasPosixAcl(d), hasNfs4Acl(d)); err != nil {
+				return err
+			}
+		} else if acltype := d.Get("acltype").(string); acltype != "" && d.NewValueKnown("acltype") {
+			if err := validateAclType(acltype, hasPosixAcl(d), hasNfs4Acl(d)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return customizePropertyDiff(d, TargetFilesystem)
+}
@@ -0,0 +1,201 @@
+package provider
+
+import (
+	"testing"
+)
+
+// TestParseFilemode verifies that octal modes are accepted with or without
+// the leading zero, and that anything else is refused.
+func TestParseFilemode(t *testing.T) {
+	cases := map[string]uint64{
+		"755":  0755,
+		"0755": 0755,
+		"1777": 01777,
+		"0640": 0640,
+	}
+	for mode, expected := range cases {
+		parsed, err := parseFilemode(mode)
+		if err != nil {
+			t.Fatalf("unexpected error parsing %s: %v", mode, err)
+		}
+		if parsed != expected {
+			t.Fatalf("parsing %s: expected %o, got %o", mode, expected, parsed)
+		}
+	}
+
+	for _, mode := range []string{"", "75", "0789", "rwxr-xr-x", "17555"} {
+		if _, err := parseFilemode(mode); err == nil {
+			t.Fatalf("expected an error parsing %q", mode)
+		}
+	}
+}
+
+// TestSuppressEquivalentFilemodeDiff verifies that the mode reported by
+// stat does not show up as a diff against the configured mode.
+func TestSuppressEquivalentFilemodeDiff(t *testing.T) {
+	if !suppressEquivalentFilemodeDiff("mode", "755", "0755", nil) {
+		t.Fatalf("expected 755 and 0755 to be equivalent")
+	}
+	if suppressEquivalentFilemodeDiff("mode", "755", "0750", nil) {
+		t.Fatalf("expected 755 and 0750 to differ")
+	}
+}
+
+// TestParseFacl verifies that access and default entries are read from
+// getfacl, ignoring the effective permission comments.
+func TestParseFacl(t *testing.T) {
+	stdout := "user::rwx\nuser:alice:rwx\t\t#effective:r-x\ngroup::r-x\nmask::r-x\nother::---\ndefault:user::rwx\ndefault:group:staff:rwx\n"
+	entries, err := parseFacl(stdout)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []AclEntry{
+		{tag: "user", permissions: "rwx"},
+		{tag: "user", qualifier: "alice", permissions: "rwx"},
+		{tag: "group", permissions: "r-x"},
+		{tag: "mask", permissions: "r-x"},
+		{tag: "other", permissions: "---"},
+		{tag: "user", permissions: "rwx", isDefault: true},
+		{tag: "group", qualifier: "staff", permissions: "rwx", isDefault: true},
+	}
+	if len(entries) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, entries)
+	}
+	for i := range expected {
+		if entries[i] != expected[i] {
+			t.Fatalf("entry %d: expected %v, got %v", i, expected[i], entries[i])
+		}
+	}
+
+	if _, err := parseFacl("user:alice"); err == nil {
+		t.Fatalf("expected an error for a malformed entry")
+	}
+}
+
+// TestFlattenAclEntries verifies that the entries every file has, and the
+// masks setfacl adds, only show up in state when they are configured.
+func TestFlattenAclEntries(t *testing.T) {
+	entries := []AclEntry{
+		{tag: "user", permissions: "rwx"},
+		{tag: "user", qualifier: "alice", permissions: "rwx"},
+		{tag: "group", permissions: "r-x"},
+		{tag: "mask", permissions: "rwx"},
+		{tag: "other", permissions: "---"},
+		{tag: "group", qualifier: "staff", permissions: "rwx", isDefault: true},
+		{tag: "mask", permissions: "rwx", isDefault: true},
+	}
+	configured := []AclEntry{
+		{tag: "user", qualifier: "alice", permissions: "r-x"},
+		{tag: "other", permissions: "---"},
+		{tag: "group", qualifier: "staff", permissions: "rwx", isDefault: true},
+	}
+
+	blocks := flattenAclEntries(entries, configured)
+	if len(blocks) != 3 {
+		t.Fatalf("expected 3 entries, got %v", blocks)
+	}
+	if blocks[0].(map[string]interface{})["permissions"] != "rwx" {
+		t.Fatalf("expected drift in the permissions of alice to be kept, got %v", blocks[0])
+	}
+}
+
+// TestParseNfs4Acl verifies that the file comment printed by nfs4_getfacl
+// is skipped.
+func TestParseNfs4Acl(t *testing.T) {
+	entries := parseNfs4Acl("# file: /srv/share\nA::OWNER@:rwaDxtTcCy\nA:g:GROUP@:rxtncy\nA::EVERYONE@:rxtncy\n")
+	if len(entries) != 3 || entries[0] != "A::OWNER@:rwaDxtTcCy" {
+		t.Fatalf("unexpected entries: %v", entries)
+	}
+}
+
+// TestValidateMountedState verifies that mounted = true is refused at plan
+// time on filesystems which can never be mounted.
+func TestValidateMountedState(t *testing.T) {
+	cases := []struct {
+		mountpoint  string
+		canmount    string
+		legacyMount bool
+		valid       bool
+	}{
+		{"/srv/data", "on", false, true},
+		{"/srv/data", "noauto", false, true},
+		{"/srv/data", "", false, true},
+		{"legacy", "on", true, true},
+		{"none", "on", false, false},
+		{"legacy", "on", false, false},
+		{"/srv/data", "off", false, false},
+		{"legacy", "off", true, false},
+	}
+
+	for _, c := range cases {
+		err := validateMountedState("tank/data", c.mountpoint, c.canmount, c.legacyMount)
+		if c.valid && err != nil {
+			t.Fatalf("unexpected error for mountpoint %s and canmount %s: %v", c.mountpoint, c.canmount, err)
+		}
+		if !c.valid && err == nil {
+			t.Fatalf("expected an error for mountpoint %s and canmount %s", c.mountpoint, c.canmount)
+		}
+	}
+}
+
+// TestDecideMountedState verifies that filesystems are only mounted or
+// unmounted when mounted is set or they have a legacy_mount, and only if
+// they are not already in the desired state.
+func TestDecideMountedState(t *testing.T) {
+	yes, no := true, false
+	cases := []struct {
+		configured  *bool
+		legacyMount bool
+		mounted     bool
+		mount       bool
+		unmount     bool
+	}{
+		{nil, false, false, false, false},
+		{nil, false, true, false, false},
+		{nil, true, false, true, false},
+		{nil, true, true, false, false},
+		{&yes, false, false, true, false},
+		{&yes, false, true, false, false},
+		{&no, false, true, false, true},
+		{&no, false, false, false, false},
+		{&no, true, true, false, true},
+	}
+
+	for i, c := range cases {
+		mount, unmount := decideMountedState(c.configured, c.legacyMount, c.mounted)
+		if mount != c.mount || unmount != c.unmount {
+			t.Fatalf("case %d: expected mount %t and unmount %t, got %t and %t", i, c.mount, c.unmount, mount, unmount)
+		}
+	}
+}
+
+// TestValidateAclType verifies that acl entries are only accepted with the
+// acltype that supports them.
+func TestValidateAclType(t *testing.T) {
+	cases := []struct {
+		acltype string
+		posix   bool
+		nfs4    bool
+		valid   bool
+	}{
+		{"off", false, false, true},
+		{"off", true, false, false},
+		{"off", false, true, false},
+		{"posix", true, false, true},
+		{"posixacl", true, false, true},
+		{"posix", false, true, false},
+		{"nfsv4", false, true, true},
+		{"nfsv4", true, false, false},
+	}
+
+	for _, c := range cases {
+		err := validateAclType(c.acltype, c.posix, c.nfs4)
+		if c.valid && err != nil {
+			t.Fatalf("unexpected error for acltype %s: %v", c.acltype, err)
+		}
+		if !c.valid && err == nil {
+			t.Fatalf("expected an error for acltype %s with acl %t and nfs4_acl %t", c.acltype, c.posix, c.nfs4)
+		}
+	}
+}
@@ -4,19 +4,88 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"regexp"
+	"strings"
+	"time"
 
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
 )
 
+// Changes to devices are validated by resourcePoolCustomizeDiff, which decides whether they can be applied to the
+// existing pool or force a new one.
 var vdevSchema = &schema.Resource{
 	Schema: map[string]*schema.Schema{
 		"path": {
 			Type:        schema.TypeString,
-			Description: "Device path of the vdev to add",
-			Required:    true,
-			ForceNew:    true,
+			Description: "Device path of the vdev to add. Computed when the device is selected using `by_id`, `serial` or `wwn`",
+			Optional:    true,
+			Computed:    true,
+			// Once a device has been replaced, the path can be updated to the replacement without recreating the pool.
+			DiffSuppressFunc: func(k, old, new string, d *schema.ResourceData) bool {
+				replacedBy := strings.TrimSuffix(k, "path") + "replaced_by"
+				return old != "" && d.Get(replacedBy).(string) == old
+			},
+		},
+		"by_id": {
+			Type:        schema.TypeString,
+			Description: "Name of the device in /dev/disk/by-id",
+			Optional:    true,
+		},
+		"serial": {
+			Type:        schema.TypeString,
+			Description: "Serial number of the disk, resolved to its /dev/disk/by-id path on the host",
+			Optional:    true,
+		},
+		"wwn": {
+			Type:        schema.TypeString,
+			Description: "World Wide Name of the disk (e.g. `0x5000c500a1b2c3d4`), resolved to its /dev/disk/by-id/wwn-* path",
+			Optional:    true,
+		},
+		"offline": {
+			Type:        schema.TypeBool,
+			Description: "Take the device offline using `zpool offline`. Defaults to `false`",
+			Optional:    true,
+			Default:     false,
+		},
+		"replaced_by": {
+			Type:        schema.TypeString,
+			Description: "Path of a new device to replace this device with using `zpool replace`. The provider waits for the replacement to finish and the old device to be detached. Once replaced, `path` can be updated to the new device.",
+			Optional:    true,
+		},
+		"wipe": {
+			Type:        schema.TypeBool,
+			Description: "Clear any existing partition tables and filesystem signatures from the device before creating the pool, including the zfs labels of exported or destroyed pools on the device and its partitions. Devices which are part of an imported pool are refused. Only used when creating the pool. Defaults to `false`",
+			Optional:    true,
+			Default:     false,
+		},
+		"partition": {
+			Type:        schema.TypeList,
+			Description: "Create a partition on the device using `sgdisk` before creating the pool, and use that partition as the vdev instead of the whole device. Only used when creating the pool",
+			Optional:    true,
+			MaxItems:    1,
+			Elem: &schema.Resource{
+				Schema: map[string]*schema.Schema{
+					"size": {
+						Type:        schema.TypeString,
+						Description: "Size of the partition, e.g. `2G`",
+						Required:    true,
+					},
+					"number": {
+						Type:        schema.TypeInt,
+						Description: "Partition number. Defaults to `1`",
+						Optional:    true,
+						Default:     1,
+					},
+					"type_code": {
+						Type:        schema.TypeString,
+						Description: "GPT partition type code. Defaults to `BF01` (Solaris /usr & Apple ZFS)",
+						Optional:    true,
+						Default:     "BF01",
+					},
+				},
+			},
 		},
 	},
 }
@@ -27,7 +96,26 @@ var mirrorSchema = &schema.Resource{
 			Description: "Device(s) which make up the mirror. Repeat the block for multiple devices",
 			Type:        schema.TypeList,
 			Required:    true,
-			ForceNew:    true,
+			Elem:        vdevSchema,
+			MinItems:    2,
+		},
+	},
+}
+
+var raidzSchema = &schema.Resource{
+	Schema: map[string]*schema.Schema{
+		"parity": {
+			Description:      "Number of parity devices in the raidz vdev (raidz1, raidz2 or raidz3). Defaults to `1`",
+			Type:             schema.TypeInt,
+			Optional:         true,
+			Default:          1,
+			ForceNew:         true,
+			ValidateDiagFunc: validation.ToDiagFunc(validation.IntBetween(1, 3)),
+		},
+		"device": {
+			Description: "Device(s) which make up the raidz vdev. Repeat the block for multiple devices. Adding devices expands the vdev using `zpool attach`, which requires OpenZFS 2.3 or later",
+			Type:        schema.TypeList,
+			Required:    true,
 			Elem:        vdevSchema,
 			MinItems:    2,
 		},
@@ -41,9 +129,10 @@ var propertySchema = schema.Schema{
 	Elem: &schema.Resource{
 		Schema: map[string]*schema.Schema{
 			"name": {
-				Description: "The name of the property to configure",
-				Type:        schema.TypeString,
-				Required:    true,
+				Description:      "The name of the property to configure. Properties unknown to the provider are passed to zfs without validation, unless they are close to the name of a known property",
+				Type:             schema.TypeString,
+				Required:         true,
+				ValidateDiagFunc: validation.ToDiagFunc(validatePropertyName),
 			},
 			"value": {
 				Description: "Value of the property",
@@ -78,6 +167,29 @@ var propertyModeSchema = schema.Schema{
 	ValidateDiagFunc: validation.ToDiagFunc(validation.StringInSlice([]string{"defined", "native", "all"}, false)),
 }
 
+var userPropertiesSchema = schema.Schema{
+	Description:      "User properties to set, such as `com.example:owner`. Names must contain a colon, see man zfsprops for the naming rules.",
+	Type:             schema.TypeMap,
+	Optional:         true,
+	Elem:             &schema.Schema{Type: schema.TypeString},
+	ValidateDiagFunc: validation.ToDiagFunc(validateUserProperties),
+}
+
+var userPropertiesModeSchema = schema.Schema{
+	Description: `
+		Which user properties to manage.
+
+		"declared" means only manage the user properties present in user_properties. This is the default.
+
+		"exact" means that any other user property set on the resource is removed, so that user_properties matches
+		exactly. Be careful, as other tools might use user properties to track information they depend on.
+	`,
+	Type:             schema.TypeString,
+	Default:          "declared",
+	Optional:         true,
+	ValidateDiagFunc: validation.ToDiagFunc(validation.StringInSlice([]string{"declared", "exact"}, false)),
+}
+
 var propertiesSchema = schema.Schema{
 	Description: "Formatted versions of all zfs properties.",
 	Type:        schema.TypeMap,
@@ -85,6 +197,20 @@ var propertiesSchema = schema.Schema{
 	Elem:        schema.TypeString,
 }
 
+var propertySourcesSchema = schema.Schema{
+	Description: "Source of each zfs property, one of `local`, `default`, `inherited`, `received`, `temporary` or `none`.",
+	Type:        schema.TypeMap,
+	Computed:    true,
+	Elem:        schema.TypeString,
+}
+
+var inheritedFromSchema = schema.Schema{
+	Description: "Name of the dataset each inherited zfs property is inherited from.",
+	Type:        schema.TypeMap,
+	Computed:    true,
+	Elem:        schema.TypeString,
+}
+
 var rawPropertiesSchema = schema.Schema{
 	Description: "Parseable versions of all zfs properties.",
 	Type:        schema.TypeMap,
@@ -106,6 +232,12 @@ func resourcePool() *schema.Resource {
 			StateContext: schema.ImportStatePassthroughContext,
 		},
 
+		CustomizeDiff: resourcePoolCustomizeDiff,
+
+		Timeouts: &schema.ResourceTimeout{
+			Update: schema.DefaultTimeout(60 * time.Minute),
+		},
+
 		Schema: map[string]*schema.Schema{
 			"name": {
 				Description: "Name of the zpool.",
@@ -118,22 +250,77 @@ func resourcePool() *schema.Resource {
 				Optional:    true,
 				Elem:        mirrorSchema,
 			},
+			"raidz": {
+				Description: "Defines a raidz vdev",
+				Type:        schema.TypeList,
+				Optional:    true,
+				Elem:        raidzSchema,
+			},
 			"device": {
 				Description: "Defines a striped vdev",
 				Type:        schema.TypeList,
 				Optional:    true,
 				AtLeastOneOf: []string{
-					"device", "mirror",
+					"device", "mirror", "raidz",
 				},
 				ConflictsWith: []string{
-					"mirror",
+					"mirror", "raidz",
 				},
 				Elem: vdevSchema,
 			},
-			"property":       &propertySchema,
-			"property_mode":  &propertyModeSchema,
-			"properties":     &propertiesSchema,
-			"raw_properties": &rawPropertiesSchema,
+			"log": {
+				Description: "Defines a separate intent log (SLOG) device",
+				Type:        schema.TypeList,
+				Optional:    true,
+				Elem:        vdevSchema,
+			},
+			"cache": {
+				Description: "Defines a cache (L2ARC) device",
+				Type:        schema.TypeList,
+				Optional:    true,
+				Elem:        vdevSchema,
+			},
+			"spare": {
+				Description: "Defines a hot spare device",
+				Type:        schema.TypeList,
+				Optional:    true,
+				Elem:        vdevSchema,
+			},
+			"features": {
+				Description: "Feature flags which must be enabled on the pool, without the `feature@` prefix. Features cannot be disabled once enabled, so removing a feature from this set has no effect.",
+				Type:        schema.TypeSet,
+				Optional:    true,
+				Elem: &schema.Schema{
+					Type:             schema.TypeString,
+					ValidateDiagFunc: validation.ToDiagFunc(validation.StringMatch(regexp.MustCompile(`^[a-z0-9_]+$`), "feature names must be given without the feature@ prefix")),
+				},
+			},
+			"feature_states": {
+				Description: "State of every feature flag supported by the pool. One of `disabled`, `enabled` or `active`.",
+				Type:        schema.TypeMap,
+				Computed:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+			},
+			"compatibility": {
+				Description: "Comma-separated list of compatibility feature set files (see man zpool-features), or `off`/`legacy`. Restricts which features are enabled when the pool is created or upgraded.",
+				Type:        schema.TypeString,
+				Optional:    true,
+				Computed:    true,
+			},
+			"upgrade": {
+				Description: "Run `zpool upgrade` to enable all supported features (restricted by `compatibility`). Defaults to `false`",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+			},
+			"property":             &propertySchema,
+			"property_mode":        &propertyModeSchema,
+			"user_properties":      &userPropertiesSchema,
+			"user_properties_mode": &userPropertiesModeSchema,
+			"properties":           &propertiesSchema,
+			"raw_properties":       &rawPropertiesSchema,
+			"property_sources":     &propertySourcesSchema,
+			"inherited_from":       &inheritedFromSchema,
 		},
 	}
 }
@@ -148,7 +335,7 @@ func resourcePoolCreate(ctx context.Context, d *schema.ResourceData, meta interf
 		log.Printf("[DEBUG] zpool %s already exists!", poolName)
 	}
 
-	log.Printf("[DEBUG] check: %s, %s", pool, err)
+	log.Printf("[DEBUG] check: %v, %s", pool, err)
 
 	if err != nil {
 		switch err := err.(type) {
@@ -167,9 +354,29 @@ func resourcePoolCreate(ctx context.Context, d *schema.ResourceData, meta interf
 		}
 	}
 
-	vdev_spec := parseVdevSpecification(d.Get("mirror"), d.Get("device"))
+	if err := resolveVdevSelectors(config, d); err != nil {
+		return diag.FromErr(err)
+	}
+
+	if err := prepareVdevDevices(config, d); err != nil {
+		return diag.FromErr(err)
+	}
+
+	vdev_spec := parseVdevSpecification(d.Get("mirror"), d.Get("raidz"), d.Get("device"))
+	for _, class := range auxiliaryVdevClasses {
+		vdev_spec += parseAuxiliaryVdevSpecification(class, d.Get(class).([]interface{}))
+	}
 
 	properties := parsePropertyBlocks(d.Get("property").(*schema.Set).List())
+	for name, value := range mergeProperties(getPoolOverrideProperties(d), getUserProperties(d)) {
+		if _, ok := properties[name]; ok {
+			return diag.FromErr(fmt.Errorf("don't set '%s' as a property block, use the dedicated attribute instead", name))
+		}
+		properties[name] = value
+	}
+	for _, feature := range d.Get("features").(*schema.Set).List() {
+		properties["feature@"+feature.(string)] = "enabled"
+	}
 
 	pool, err = createPool(config, &CreatePool{
 		name:       poolName,
@@ -181,6 +388,19 @@ func resourcePoolCreate(ctx context.Context, d *schema.ResourceData, meta interf
 		return diag.FromErr(err)
 	}
 
+	if d.Get("upgrade").(bool) {
+		if err := upgradePool(config, poolName); err != nil {
+			return diag.FromErr(err)
+		}
+		if pool, err = describePool(config, poolName, getPropertyNames(d)); err != nil {
+			return diag.FromErr(err)
+		}
+	}
+
+	if pool.compatibleFeatures, err = readCompatibleFeatures(config, pool.properties["compatibility"].value); err != nil {
+		return diag.FromErr(err)
+	}
+
 	// We're setting the ID here because the dataset DOES exist, even if the mountpoint
 	// is not properly configured!
 	log.Printf("[DEBUG] committing guid: %s", pool.guid)
@@ -212,20 +432,47 @@ func resourcePoolRead(ctx context.Context, d *schema.ResourceData, meta interfac
 		return diag.FromErr(err)
 	}
 
+	if pool.compatibleFeatures, err = readCompatibleFeatures(config, pool.properties["compatibility"].value); err != nil {
+		return diag.FromErr(err)
+	}
+
 	return populateResourceDataPool(d, *pool)
 }
 
 func populateResourceDataPool(d *schema.ResourceData, pool Pool) diag.Diagnostics {
 	var diags diag.Diagnostics
 
-	devices := make([]map[string]interface{}, len(pool.layout.striped))
-	for device_id, device := range pool.layout.striped {
-		devices[device_id] = flattenDevice(device)
-	}
+	devices := mergeDevices(d.Get("device").([]interface{}), pool.layout.striped)
 
+	configuredMirrors := d.Get("mirror").([]interface{})
 	mirrors := make([]map[string]interface{}, len(pool.layout.mirrors))
 	for mirror_id, mirror := range pool.layout.mirrors {
 		mirrors[mirror_id] = flattenMirror(mirror)
+		if mirror_id < len(configuredMirrors) {
+			configured := configuredMirrors[mirror_id].(map[string]interface{})["device"].([]interface{})
+			mirrors[mirror_id]["device"] = mergeDevices(configured, mirror.devices)
+		}
+	}
+
+	auxiliary := map[string][]Device{
+		"log":   pool.layout.logs,
+		"cache": pool.layout.cache,
+		"spare": pool.layout.spares,
+	}
+	for _, class := range auxiliaryVdevClasses {
+		if err := d.Set(class, mergeDevices(d.Get(class).([]interface{}), auxiliary[class])); err != nil {
+			return diag.FromErr(err)
+		}
+	}
+
+	configuredRaidz := d.Get("raidz").([]interface{})
+	raidz := make([]map[string]interface{}, len(pool.layout.raidz))
+	for raidz_id, vdev := range pool.layout.raidz {
+		raidz[raidz_id] = flattenRaidz(vdev)
+		if raidz_id < len(configuredRaidz) {
+			configured := configuredRaidz[raidz_id].(map[string]interface{})["device"].([]interface{})
+			raidz[raidz_id]["device"] = mergeDevices(configured, vdev.devices)
+		}
 	}
 
 	if err := d.Set("device", devices); err != nil {
@@ -236,14 +483,268 @@ func populateResourceDataPool(d *schema.ResourceData, pool Pool) diag.Diagnostic
 		return diag.FromErr(err)
 	}
 
-	if err := updatePropertiesInState(d, pool.properties, []string{}); err != nil {
+	if err := d.Set("raidz", raidz); err != nil {
+		return diag.FromErr(err)
+	}
+
+	// User properties are set on the root dataset of the pool.
+	ignoredProperties := getIgnoredUserProperties(d, pool.properties)
+	if err := updateUserPropertiesInState(d, pool.properties); err != nil {
+		return diag.FromErr(err)
+	}
+
+	if err := updatePropertiesInState(d, pool.properties, ignoredProperties); err != nil {
+		return diag.FromErr(err)
+	}
+
+	if err := d.Set("compatibility", pool.properties["compatibility"].value); err != nil {
+		return diag.FromErr(err)
+	}
+
+	featureStates := getFeatureStates(pool.properties)
+	if err := d.Set("feature_states", featureStates); err != nil {
+		return diag.FromErr(err)
+	}
+
+	// Only report the desired features which are actually enabled, so disabled ones show up as a diff.
+	features := make([]interface{}, 0)
+	upgraded := true
+	for _, feature := range d.Get("features").(*schema.Set).List() {
+		if state, ok := featureStates[feature.(string)]; ok && state != "disabled" {
+			features = append(features, feature)
+		}
+	}
+	for feature, state := range featureStates {
+		// Features left out of the compatibility feature sets are disabled by design, and not enabled by an upgrade.
+		if state == "disabled" && pool.isFeatureCompatible(feature) {
+			upgraded = false
+		}
+	}
+	if err := d.Set("features", features); err != nil {
 		return diag.FromErr(err)
 	}
 
+	// Likewise, if an upgrade was requested but features have been disabled since, report that no upgrade was done.
+	if d.Get("upgrade").(bool) && !upgraded {
+		if err := d.Set("upgrade", false); err != nil {
+			return diag.FromErr(err)
+		}
+	}
+
 	d.SetId(pool.guid)
 	return diags
 }
 
+// resolveVdevSelectors resolves the by_id, serial and wwn selectors of all configured devices into paths on the host.
+func resolveVdevSelectors(config *Config, d *schema.ResourceData) error {
+	resolve := func(devices []interface{}) error {
+		for _, device := range devices {
+			device := device.(map[string]interface{})
+			path, err := resolveDevicePath(config, device)
+			if err != nil {
+				return err
+			}
+			device["path"] = path
+		}
+		return nil
+	}
+
+	for _, class := range append([]string{"device"}, auxiliaryVdevClasses...) {
+		devices := d.Get(class).([]interface{})
+		if err := resolve(devices); err != nil {
+			return err
+		}
+		if err := d.Set(class, devices); err != nil {
+			return err
+		}
+	}
+
+	for _, group := range []string{"mirror", "raidz"} {
+		vdevs := d.Get(group).([]interface{})
+		for _, vdev := range vdevs {
+			if err := resolve(vdev.(map[string]interface{})["device"].([]interface{})); err != nil {
+				return err
+			}
+		}
+		if err := d.Set(group, vdevs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// prepareVdevDevices wipes and partitions the configured devices which ask for it, ahead of creating the pool.
+func prepareVdevDevices(config *Config, d *schema.ResourceData) error {
+	devices := d.Get("device").([]interface{})
+	for _, class := range auxiliaryVdevClasses {
+		devices = append(devices, d.Get(class).([]interface{})...)
+	}
+	for _, group := range []string{"mirror", "raidz"} {
+		for _, vdev := range d.Get(group).([]interface{}) {
+			devices = append(devices, vdev.(map[string]interface{})["device"].([]interface{})...)
+		}
+	}
+
+	for _, device := range devices {
+		device := device.(map[string]interface{})
+		path := device["path"].(string)
+		partitions := device["partition"].([]interface{})
+		wipe := device["wipe"].(bool)
+		if !wipe && len(partitions) == 0 {
+			continue
+		}
+
+		poolName, err := getPoolUsingDevice(config, path)
+		if err != nil {
+			return err
+		}
+		if poolName != "" {
+			return fmt.Errorf("refusing to prepare device %s, as it is in use by zpool %s", path, poolName)
+		}
+
+		// Labels left by exported or destroyed pools are cleared by wiping the device.
+		if !wipe {
+			member, err := isZfsMember(config, path)
+			if err != nil {
+				return err
+			}
+			if member {
+				return fmt.Errorf("refusing to partition device %s, as it holds a zfs label. Set wipe = true to clear it", path)
+			}
+		}
+
+		if wipe {
+			log.Printf("[DEBUG] wiping device %s", path)
+			if err := wipeDevice(config, path); err != nil {
+				return err
+			}
+		}
+
+		if len(partitions) > 0 && partitions[0] != nil {
+			partition := partitions[0].(map[string]interface{})
+			log.Printf("[DEBUG] partitioning device %s: %v", path, partition)
+			if err := createPartition(config, path, &CreatePartition{
+				number:   partition["number"].(int),
+				size:     partition["size"].(string),
+				typeCode: partition["type_code"].(string),
+			}); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// applyDeviceChanges takes devices offline or online, and replaces devices, as requested by the device blocks.
+func applyDeviceChanges(config *Config, d *schema.ResourceData, poolName string, timeout time.Duration) error {
+	keys := make([]string, 0)
+	for _, class := range append([]string{"device"}, auxiliaryVdevClasses...) {
+		for device_id := range d.Get(class).([]interface{}) {
+			keys = append(keys, fmt.Sprintf("%s.%d", class, device_id))
+		}
+	}
+	for _, group := range []string{"mirror", "raidz"} {
+		for vdev_id, vdev := range d.Get(group).([]interface{}) {
+			for device_id := range vdev.(map[string]interface{})["device"].([]interface{}) {
+				keys = append(keys, fmt.Sprintf("%s.%d.device.%d", group, vdev_id, device_id))
+			}
+		}
+	}
+
+	replacing := false
+	for _, key := range keys {
+		device := d.Get(key).(map[string]interface{})
+		path := getVdevPath(device)
+
+		if d.HasChange(key + ".offline") {
+			if device["offline"].(bool) {
+				log.Printf("[DEBUG] taking device %s offline", path)
+				if err := offlineDevice(config, poolName, path); err != nil {
+					return err
+				}
+			} else {
+				log.Printf("[DEBUG] bringing device %s online", path)
+				if err := onlineDevice(config, poolName, path); err != nil {
+					return err
+				}
+			}
+		}
+
+		if replacedBy := device["replaced_by"].(string); replacedBy != "" && d.HasChange(key+".replaced_by") {
+			log.Printf("[DEBUG] replacing device %s with %s", path, replacedBy)
+			if err := replaceDevice(config, poolName, path, replacedBy); err != nil {
+				return err
+			}
+			replacing = true
+		}
+	}
+
+	// The old device is only detached once the replacement is done, which can take longer than the resilver itself.
+	if replacing {
+		return waitForPoolActivity(config, poolName, "replace", timeout)
+	}
+	return nil
+}
+
+// mergeDevices flattens the devices reported by zpool, keeping the configured device blocks (and their selectors) for
+// devices which refer to the same disk, e.g. when zpool reports the partition it created on a whole disk.
+func mergeDevices(configured []interface{}, actual []Device) []map[string]interface{} {
+	devices := make([]map[string]interface{}, len(actual))
+	for device_id, device := range actual {
+		devices[device_id] = flattenDevice(device)
+		if device_id < len(configured) && configured[device_id] != nil {
+			block := configured[device_id].(map[string]interface{})
+			path, _ := block["path"].(string)
+			replacedBy, _ := block["replaced_by"].(string)
+			if devicePathsMatch(path, device.path) {
+				devices[device_id] = block
+			} else if replacedBy != "" && devicePathsMatch(replacedBy, device.path) {
+				// The device has been replaced, so track the replacement from now on.
+				devices[device_id] = block
+				devices[device_id]["path"] = replacedBy
+			} else {
+				continue
+			}
+			devices[device_id]["offline"] = device.state == "OFFLINE"
+		}
+	}
+	return devices
+}
+
+// getPoolOverrideProperties returns the pool properties which are managed through dedicated attributes.
+func getPoolOverrideProperties(d *schema.ResourceData) map[string]string {
+	properties := make(map[string]string)
+	if compatibility, ok := d.GetOk("compatibility"); ok {
+		properties["compatibility"] = compatibility.(string)
+	}
+	return properties
+}
+
+func applyPoolFeatures(config *Config, d *schema.ResourceData, poolName string, actualProperties map[string]Property) error {
+	featureStates := getFeatureStates(actualProperties)
+	for _, feature := range d.Get("features").(*schema.Set).List() {
+		feature := feature.(string)
+		state, ok := featureStates[feature]
+		if !ok {
+			return fmt.Errorf("feature %s is not supported by zpool %s", feature, poolName)
+		}
+		if state == "disabled" {
+			if err := enablePoolFeature(config, poolName, feature); err != nil {
+				return err
+			}
+		}
+	}
+
+	if d.Get("upgrade").(bool) && d.HasChange("upgrade") {
+		if err := upgradePool(config, poolName); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func resourcePoolUpdate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
 	config := meta.(*Config)
 	old_name, err := getPoolNameByGuid(config, d.Id())
@@ -263,14 +764,318 @@ func resourcePoolUpdate(ctx context.Context, d *schema.ResourceData, meta interf
 		return diag.FromErr(err)
 	}
 
-	err = applyPropertyDiff(config, d, poolName, pool.properties, make(map[string]string))
+	if err := removeUserProperties(config, d, poolName); err != nil {
+		return diag.FromErr(err)
+	}
+
+	overrideProperties := mergeProperties(getPoolOverrideProperties(d), getUserProperties(d))
+	err = applyPropertyDiff(config, d, poolName, pool.properties, overrideProperties)
 	if err != nil {
 		return diag.FromErr(err)
 	}
 
+	if err := applyPoolFeatures(config, d, poolName, pool.properties); err != nil {
+		return diag.FromErr(err)
+	}
+
+	if err := removeVdevs(config, d, poolName, pool.layout, d.Timeout(schema.TimeoutUpdate)); err != nil {
+		return diag.FromErr(err)
+	}
+
+	if err := addAuxiliaryVdevs(config, d, poolName); err != nil {
+		return diag.FromErr(err)
+	}
+
+	if err := applyDeviceChanges(config, d, poolName, d.Timeout(schema.TimeoutUpdate)); err != nil {
+		return diag.FromErr(err)
+	}
+
+	if err := expandRaidz(ctx, config, d, poolName, pool.layout, d.Timeout(schema.TimeoutUpdate)); err != nil {
+		return diag.FromErr(err)
+	}
+
 	return resourcePoolRead(ctx, d, meta)
 }
 
+// expandRaidz attaches devices which have been added to the existing raidz vdevs, one at a time.
+func expandRaidz(ctx context.Context, config *Config, d *schema.ResourceData, poolName string, layout PoolLayout, timeout time.Duration) error {
+	if !d.HasChange("raidz") {
+		return nil
+	}
+
+	deadline := time.Now().Add(timeout)
+	vdevs := d.Get("raidz").([]interface{})
+	for raidz_id, vdev := range layout.raidz {
+		if raidz_id >= len(vdevs) {
+			break
+		}
+		old, _ := d.GetChange(fmt.Sprintf("raidz.%d.device", raidz_id))
+		devices := vdevs[raidz_id].(map[string]interface{})["device"].([]interface{})
+		for device_id := len(old.([]interface{})); device_id < len(devices); device_id++ {
+			device := devices[device_id].(map[string]interface{})
+			path, err := resolveDevicePath(config, device)
+			if err != nil {
+				return err
+			}
+			// Keep the resolved path next to the selector, so that the device is recognized when reading the pool.
+			device["path"] = path
+
+			log.Printf("[DEBUG] expanding %s with %s", vdev.name, path)
+			if err := attachDevice(config, poolName, vdev.name, path); err != nil {
+				return err
+			}
+
+			// Only one expansion can run at a time, so wait for this one before attaching the next device.
+			if err := waitForRaidzExpansion(ctx, config, poolName, time.Until(deadline)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return d.Set("raidz", vdevs)
+}
+
+// removeVdevs removes the top-level vdevs and auxiliary devices which are no longer defined, waiting for the data on
+// removed top-level vdevs to be evacuated.
+func removeVdevs(config *Config, d *schema.ResourceData, poolName string, layout PoolLayout, timeout time.Duration) error {
+	evacuating := false
+
+	old, new := d.GetChange("device")
+	removed, _ := diffVdevBlocks(old.([]interface{}), new.([]interface{}), deviceBlocksMatch)
+	for _, device := range removed {
+		path := device.(map[string]interface{})["path"].(string)
+		for _, actual := range layout.striped {
+			if devicePathsMatch(path, actual.path) {
+				path = actual.path
+			}
+		}
+		log.Printf("[DEBUG] removing device %s", path)
+		if err := removeVdev(config, poolName, path); err != nil {
+			return err
+		}
+		evacuating = true
+	}
+
+	old, new = d.GetChange("mirror")
+	removed, _ = diffVdevBlocks(old.([]interface{}), new.([]interface{}), mirrorBlocksMatch)
+	for _, mirror := range removed {
+		devices := mirror.(map[string]interface{})["device"].([]interface{})
+		name := ""
+		for _, actual := range layout.mirrors {
+			if len(actual.devices) > 0 && len(devices) > 0 && devicePathsMatch(devices[0].(map[string]interface{})["path"].(string), actual.devices[0].path) {
+				name = actual.name
+			}
+		}
+		if name == "" {
+			return fmt.Errorf("could not find the mirror to remove in zpool %s", poolName)
+		}
+		log.Printf("[DEBUG] removing mirror %s", name)
+		if err := removeVdev(config, poolName, name); err != nil {
+			return err
+		}
+		evacuating = true
+	}
+
+	// Auxiliary devices hold no data which needs to be evacuated, so they can always be removed immediately.
+	for _, class := range auxiliaryVdevClasses {
+		old, new := d.GetChange(class)
+		removed, _ := diffVdevBlocks(old.([]interface{}), new.([]interface{}), deviceBlocksMatch)
+		for _, device := range removed {
+			path := getVdevPath(device.(map[string]interface{}))
+			log.Printf("[DEBUG] removing %s device %s", class, path)
+			if err := removeVdev(config, poolName, path); err != nil {
+				return err
+			}
+		}
+	}
+
+	if evacuating {
+		return waitForPoolActivity(config, poolName, "remove", timeout)
+	}
+	return nil
+}
+
+// addAuxiliaryVdevs adds newly defined log, cache and spare devices to the pool.
+func addAuxiliaryVdevs(config *Config, d *schema.ResourceData, poolName string) error {
+	for _, class := range auxiliaryVdevClasses {
+		old, new := d.GetChange(class)
+		_, added := diffVdevBlocks(old.([]interface{}), new.([]interface{}), deviceBlocksMatch)
+		if len(added) == 0 {
+			continue
+		}
+		for _, device := range added {
+			device := device.(map[string]interface{})
+			path, err := resolveDevicePath(config, device)
+			if err != nil {
+				return err
+			}
+			device["path"] = path
+		}
+		if err := addVdevs(config, poolName, parseAuxiliaryVdevSpecification(class, added)); err != nil {
+			return err
+		}
+		// The added blocks are part of the new list, which now holds the resolved paths next to the selectors.
+		if err := d.Set(class, new); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deviceBlocksMatch reports whether a device block in the state and one in the plan refer to the same device.
+func deviceBlocksMatch(old map[string]interface{}, new map[string]interface{}) bool {
+	if path, _ := new["path"].(string); path != "" {
+		if path == old["path"] || path == old["replaced_by"] {
+			return true
+		}
+	}
+	for _, selector := range []string{"by_id", "serial", "wwn"} {
+		if value, _ := new[selector].(string); value != "" {
+			return value == old[selector]
+		}
+	}
+	return false
+}
+
+func mirrorBlocksMatch(old map[string]interface{}, new map[string]interface{}) bool {
+	oldDevices := old["device"].([]interface{})
+	newDevices := new["device"].([]interface{})
+	if len(oldDevices) != len(newDevices) {
+		return false
+	}
+	for device_id := range newDevices {
+		if !deviceBlocksMatch(oldDevices[device_id].(map[string]interface{}), newDevices[device_id].(map[string]interface{})) {
+			return false
+		}
+	}
+	return true
+}
+
+// raidzBlocksMatch is like mirrorBlocksMatch, but allows devices to be added to the end of the raidz vdev.
+func raidzBlocksMatch(old map[string]interface{}, new map[string]interface{}) bool {
+	oldDevices := old["device"].([]interface{})
+	newDevices := new["device"].([]interface{})
+	if len(newDevices) < len(oldDevices) || old["parity"] != new["parity"] {
+		return false
+	}
+	for device_id := range oldDevices {
+		if !deviceBlocksMatch(oldDevices[device_id].(map[string]interface{}), newDevices[device_id].(map[string]interface{})) {
+			return false
+		}
+	}
+	return true
+}
+
+// findSplitDevice returns the path of a single device which the configuration has in one of the added mirrors. This
+// is the layout left behind by `zpool split`, which recreating the pool would destroy the data of.
+func findSplitDevice(oldDevices []interface{}, addedMirrors []interface{}) string {
+	for _, mirror := range addedMirrors {
+		for _, newDevice := range mirror.(map[string]interface{})["device"].([]interface{}) {
+			for _, oldDevice := range oldDevices {
+				if deviceBlocksMatch(oldDevice.(map[string]interface{}), newDevice.(map[string]interface{})) {
+					return getVdevPath(oldDevice.(map[string]interface{}))
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// diffVdevBlocks pairs up the old and new vdev blocks, returning the old blocks which no longer exist, and the new
+// blocks which didn't exist before.
+func diffVdevBlocks(old []interface{}, new []interface{}, match func(map[string]interface{}, map[string]interface{}) bool) ([]interface{}, []interface{}) {
+	matched := make([]bool, len(old))
+	added := make([]interface{}, 0)
+	for _, newBlock := range new {
+		found := false
+		for old_id, oldBlock := range old {
+			if !matched[old_id] && match(oldBlock.(map[string]interface{}), newBlock.(map[string]interface{})) {
+				matched[old_id] = true
+				found = true
+				break
+			}
+		}
+		if !found {
+			added = append(added, newBlock)
+		}
+	}
+
+	removed := make([]interface{}, 0)
+	for old_id, oldBlock := range old {
+		if !matched[old_id] {
+			removed = append(removed, oldBlock)
+		}
+	}
+	return removed, added
+}
+
+func resourcePoolCustomizeDiff(ctx context.Context, d *schema.ResourceDiff, meta interface{}) error {
+	if err := customizePropertyDiff(d, TargetPool); err != nil {
+		return err
+	}
+
+	// Nothing to compare against when creating the pool.
+	if d.Id() == "" {
+		return nil
+	}
+
+	oldRaidz, newRaidz := d.GetChange("raidz")
+	removedRaidz, addedRaidz := diffVdevBlocks(oldRaidz.([]interface{}), newRaidz.([]interface{}), raidzBlocksMatch)
+	if len(removedRaidz) > 0 {
+		return fmt.Errorf("raidz vdevs cannot be removed from zpool %s, and the pool would have to be recreated. Use terraform apply -replace or taint the resource if that is intended", d.Get("name"))
+	}
+	if len(addedRaidz) > 0 {
+		return d.ForceNew("raidz")
+	}
+
+	removedTopLevel := false
+	for _, vdev := range []struct {
+		key   string
+		match func(map[string]interface{}, map[string]interface{}) bool
+	}{{"device", deviceBlocksMatch}, {"mirror", mirrorBlocksMatch}} {
+		old, new := d.GetChange(vdev.key)
+		removed, added := diffVdevBlocks(old.([]interface{}), new.([]interface{}), vdev.match)
+		if len(added) > 0 && vdev.key == "mirror" {
+			oldDevices, _ := d.GetChange("device")
+			if path := findSplitDevice(oldDevices.([]interface{}), added); path != "" {
+				return fmt.Errorf("device %s of zpool %s is no longer mirrored, which is the layout left by zpool split. Replace its mirror block with a device block to keep the pool, or use terraform apply -replace to recreate it", path, d.Get("name"))
+			}
+		}
+		if len(added) > 0 {
+			// Adding or changing top-level vdevs is not supported on existing pools.
+			return d.ForceNew(vdev.key)
+		}
+		if len(removed) > 0 {
+			removedTopLevel = true
+		}
+	}
+	if removedTopLevel && len(oldRaidz.([]interface{})) > 0 {
+		return fmt.Errorf("top-level vdevs cannot be removed from zpool %s, because it contains raidz vdevs", d.Get("name"))
+	}
+
+	expanding := false
+	for raidz_id, raidz := range newRaidz.([]interface{}) {
+		if raidz_id < len(oldRaidz.([]interface{})) {
+			oldDevices := oldRaidz.([]interface{})[raidz_id].(map[string]interface{})["device"].([]interface{})
+			if len(raidz.(map[string]interface{})["device"].([]interface{})) > len(oldDevices) {
+				expanding = true
+			}
+		}
+	}
+
+	if expanding && meta != nil {
+		version, err := getOpenZfsVersion(meta.(*Config))
+		if err != nil {
+			return err
+		}
+		if !version.atLeast(2, 3, 0) {
+			return fmt.Errorf("expanding a raidz vdev requires OpenZFS 2.3 or later, but the host is running %s", version)
+		}
+	}
+
+	return nil
+}
+
 func resourcePoolDelete(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
 	var diags diag.Diagnostics
 
@@ -0,0 +1,104 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func resourcePoolCheckpoint() *schema.Resource {
+	return &schema.Resource{
+		// This description is used by the documentation generator and the language server.
+		Description: "zpool checkpoint resource. The checkpoint is discarded when the resource is destroyed.",
+
+		CreateContext: resourcePoolCheckpointCreate,
+		ReadContext:   resourcePoolCheckpointRead,
+		DeleteContext: resourcePoolCheckpointDelete,
+
+		Schema: map[string]*schema.Schema{
+			"pool": {
+				Description: "Name of the zpool to checkpoint.",
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+			},
+			"size": {
+				Description: "Amount of space used by the checkpoint, in bytes.",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+		},
+	}
+}
+
+func resourcePoolCheckpointCreate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	config := meta.(*Config)
+	poolName := d.Get("pool").(string)
+
+	pool, err := describePool(config, poolName, []string{})
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	if err := createPoolCheckpoint(config, poolName); err != nil {
+		return diag.FromErr(err)
+	}
+
+	log.Printf("[DEBUG] committing guid: %s", pool.guid)
+	d.SetId(pool.guid)
+
+	return resourcePoolCheckpointRead(ctx, d, meta)
+}
+
+func resourcePoolCheckpointRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	config := meta.(*Config)
+
+	poolName := d.Get("pool").(string)
+	real_name, err := getPoolNameByGuid(config, d.Id())
+	if err != nil {
+		return diag.FromErr(fmt.Errorf("the zpool %s identified by guid %s could not be found. It was likely deleted on the server outside of terraform", poolName, d.Id()))
+	}
+	poolName = *real_name
+
+	if err := d.Set("pool", poolName); err != nil {
+		return diag.FromErr(err)
+	}
+
+	checkpoint, err := readPoolCheckpoint(config, poolName)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	if checkpoint == nil {
+		// The checkpoint was discarded (or rewound to) outside of terraform, so it needs to be recreated.
+		log.Printf("[DEBUG] zpool %s has no checkpoint", poolName)
+		d.SetId("")
+		return diags
+	}
+
+	if err := d.Set("size", checkpoint.rawValue); err != nil {
+		return diag.FromErr(err)
+	}
+
+	return diags
+}
+
+func resourcePoolCheckpointDelete(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	config := meta.(*Config)
+	poolName := d.Get("pool").(string)
+
+	log.Printf("[DEBUG] discarding checkpoint of pool: %s", poolName)
+	if err := discardPoolCheckpoint(config, poolName); err != nil {
+		return diag.FromErr(err)
+	}
+
+	d.SetId("")
+	return diags
+}
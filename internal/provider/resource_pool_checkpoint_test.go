@@ -0,0 +1,30 @@
+package provider
+
+import (
+	"testing"
+)
+
+// TestParseCheckpointStatus verifies that checkpoints are detected from
+// zpool status, including those which don't consume any space yet.
+func TestParseCheckpointStatus(t *testing.T) {
+	cases := map[string]string{
+		"checkpoint: created Sun Oct 18 00:24:01 2026, consumes 0":     "created Sun Oct 18 00:24:01 2026, consumes 0",
+		"checkpoint: created Sun Oct 18 00:24:01 2026, consumes 1.06M": "created Sun Oct 18 00:24:01 2026, consumes 1.06M",
+		"checkpoint: discarding": "discarding",
+	}
+
+	for line, expected := range cases {
+		stdout := "  pool: aquarium\n state: ONLINE\n  scan: none requested\n" + line + "\nconfig:\n"
+		if status := parseCheckpointStatus(stdout); status != expected {
+			t.Fatalf("parsing %q: expected %q, got %q", line, expected, status)
+		}
+	}
+}
+
+// TestParseCheckpointStatus_None verifies that pools without a checkpoint
+// are reported as such.
+func TestParseCheckpointStatus_None(t *testing.T) {
+	if status := parseCheckpointStatus("  pool: aquarium\n state: ONLINE\n  scan: none requested\nconfig:\n"); status != "" {
+		t.Fatalf("expected no checkpoint, got %q", status)
+	}
+}
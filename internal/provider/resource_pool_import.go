@@ -0,0 +1,165 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func resourcePoolImport() *schema.Resource {
+	return &schema.Resource{
+		// This description is used by the documentation generator and the language server.
+		Description: "Imports an existing zpool which is present on disk but not currently imported. The pool is exported (not destroyed) when the resource is removed.",
+
+		CreateContext: resourcePoolImportCreate,
+		ReadContext:   resourcePoolImportRead,
+		DeleteContext: resourcePoolImportDelete,
+
+		Importer: &schema.ResourceImporter{
+			StateContext: schema.ImportStatePassthroughContext,
+		},
+
+		Schema: map[string]*schema.Schema{
+			"name": {
+				Description: "Name of the zpool to import. If `guid` is set, the pool is imported under this name instead.",
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+			},
+			"guid": {
+				Description: "Guid of the zpool to import. Use this when multiple exported pools share the same name.",
+				Type:        schema.TypeString,
+				Optional:    true,
+				Computed:    true,
+				ForceNew:    true,
+			},
+			"search_directories": {
+				Description: "Directories or devices to search for the pool in (`zpool import -d`).",
+				Type:        schema.TypeList,
+				Optional:    true,
+				ForceNew:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+			},
+			"altroot": {
+				Description: "Alternate root directory to import the pool under.",
+				Type:        schema.TypeString,
+				Optional:    true,
+				ForceNew:    true,
+			},
+			"cachefile": {
+				Description: "Cachefile to record the pool configuration in. Use `none` to avoid caching the pool configuration.",
+				Type:        schema.TypeString,
+				Optional:    true,
+				ForceNew:    true,
+			},
+			"readonly": {
+				Description: "Import the pool in read-only mode. Defaults to `false`",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				ForceNew:    true,
+			},
+			"force": {
+				Description: "Force the import, even if the pool appears to be in use by another system. Defaults to `false`",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				ForceNew:    true,
+			},
+			"properties":       &propertiesSchema,
+			"raw_properties":   &rawPropertiesSchema,
+			"property_sources": &propertySourcesSchema,
+			"inherited_from":   &inheritedFromSchema,
+		},
+	}
+}
+
+func resourcePoolImportCreate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	config := meta.(*Config)
+
+	pool, err := importPool(config, expandImportPool(d))
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	log.Printf("[DEBUG] committing guid: %s", pool.guid)
+	d.SetId(pool.guid)
+
+	return populateResourceDataPoolImport(d, *pool)
+}
+
+func expandImportPool(d *schema.ResourceData) *ImportPool {
+	searchDirectories := make([]string, 0)
+	for _, directory := range d.Get("search_directories").([]interface{}) {
+		searchDirectories = append(searchDirectories, directory.(string))
+	}
+
+	return &ImportPool{
+		name:              d.Get("name").(string),
+		guid:              d.Get("guid").(string),
+		searchDirectories: searchDirectories,
+		altroot:           d.Get("altroot").(string),
+		cachefile:         d.Get("cachefile").(string),
+		readonly:          d.Get("readonly").(bool),
+		force:             d.Get("force").(bool),
+	}
+}
+
+func resourcePoolImportRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	config := meta.(*Config)
+
+	poolName := d.Get("name").(string)
+	if id := d.Id(); id != "" {
+		// If we have a Resource ID, then use that to lookup the real name
+		// of the zfs resource, in case the name has changed.
+		real_name, err := getPoolNameByGuid(config, id)
+		if err != nil {
+			return diag.FromErr(fmt.Errorf("the zpool %s identified by guid %s could not be found. It was likely exported or deleted on the server outside of terraform", poolName, id))
+		}
+		poolName = *real_name
+	}
+
+	if err := d.Set("name", poolName); err != nil {
+		return diag.FromErr(err)
+	}
+
+	pool, err := describePool(config, poolName, []string{})
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	return populateResourceDataPoolImport(d, *pool)
+}
+
+func populateResourceDataPoolImport(d *schema.ResourceData, pool Pool) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	if err := d.Set("guid", pool.guid); err != nil {
+		return diag.FromErr(err)
+	}
+
+	if err := updateCalculatedPropertiesInState(d, pool.properties); err != nil {
+		return diag.FromErr(err)
+	}
+
+	d.SetId(pool.guid)
+	return diags
+}
+
+func resourcePoolImportDelete(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	config := meta.(*Config)
+	poolName := d.Get("name").(string)
+
+	log.Printf("[DEBUG] exporting pool: %s", poolName)
+	if err := exportPool(config, poolName); err != nil {
+		return diag.FromErr(err)
+	}
+
+	d.SetId("")
+	return diags
+}
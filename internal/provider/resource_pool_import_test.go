@@ -0,0 +1,66 @@
+package provider
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// TestRenderImportArguments verifies that the configured options are passed
+// to zpool import, and that pools imported by guid are named after the
+// configured name.
+func TestRenderImportArguments(t *testing.T) {
+	d := schema.TestResourceDataRaw(t, resourcePoolImport().Schema, map[string]interface{}{
+		"name":               "tank",
+		"search_directories": []interface{}{"/dev/disk/by-id", "/srv/my images"},
+		"altroot":            "/mnt",
+		"cachefile":          "none",
+		"readonly":           true,
+		"force":              true,
+	})
+
+	expected := " -d /dev/disk/by-id -d '/srv/my images' -o altroot=/mnt -o cachefile=none -o readonly=on -f tank"
+	if arguments := renderImportArguments(expandImportPool(d)); arguments != expected {
+		t.Fatalf("expected %q, got %q", expected, arguments)
+	}
+
+	d = schema.TestResourceDataRaw(t, resourcePoolImport().Schema, map[string]interface{}{
+		"name": "restored",
+		"guid": "10451830521226491413",
+	})
+
+	expected = " 10451830521226491413 restored"
+	if arguments := renderImportArguments(expandImportPool(d)); arguments != expected {
+		t.Fatalf("expected %q, got %q", expected, arguments)
+	}
+}
+
+// TestPopulateResourceDataPoolImport verifies that the guid and properties
+// of an imported pool are read back into the state.
+func TestPopulateResourceDataPoolImport(t *testing.T) {
+	d := schema.TestResourceDataRaw(t, resourcePoolImport().Schema, map[string]interface{}{"name": "tank"})
+
+	diags := populateResourceDataPoolImport(d, Pool{
+		guid: "10451830521226491413",
+		properties: map[string]Property{
+			"readonly": {source: SourceDefault, value: "off", rawValue: "off"},
+			"altroot":  {source: SourceLocal, value: "/mnt", rawValue: "/mnt"},
+		},
+	})
+	if diags.HasError() {
+		t.Fatalf("unexpected error: %v", diags)
+	}
+
+	if id := d.Id(); id != "10451830521226491413" {
+		t.Fatalf("expected the id to be the guid, got %q", id)
+	}
+	if guid := d.Get("guid").(string); guid != "10451830521226491413" {
+		t.Fatalf("expected guid 10451830521226491413, got %q", guid)
+	}
+	if altroot := d.Get("properties.altroot").(string); altroot != "/mnt" {
+		t.Fatalf("expected the altroot property /mnt, got %q", altroot)
+	}
+	if source := d.Get("property_sources.readonly").(string); source != string(SourceDefault) {
+		t.Fatalf("expected readonly to be a default, got %q", source)
+	}
+}
@@ -0,0 +1,262 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+func resourcePoolScrub() *schema.Resource {
+	return &schema.Resource{
+		// This description is used by the documentation generator and the language server.
+		Description: "Manages scrubbing and trimming of a zpool. Change `triggers` to start a new scrub.",
+
+		CreateContext: resourcePoolScrubCreate,
+		ReadContext:   resourcePoolScrubRead,
+		UpdateContext: resourcePoolScrubUpdate,
+		DeleteContext: resourcePoolScrubDelete,
+
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(60 * time.Minute),
+			Update: schema.DefaultTimeout(60 * time.Minute),
+		},
+
+		Schema: map[string]*schema.Schema{
+			"pool": {
+				Description: "Name of the zpool to scrub.",
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+			},
+			"scrub": {
+				Description:      "Desired state of the scrub. One of `running`, `paused` or `canceled`. Defaults to `running`",
+				Type:             schema.TypeString,
+				Optional:         true,
+				Default:          "running",
+				ValidateDiagFunc: validation.ToDiagFunc(validation.StringInSlice([]string{"running", "paused", "canceled"}, false)),
+			},
+			"trim": {
+				Description:      "Desired state of the trim. One of `running`, `suspended` or `canceled`. Trimming is left alone if unset.",
+				Type:             schema.TypeString,
+				Optional:         true,
+				ValidateDiagFunc: validation.ToDiagFunc(validation.StringInSlice([]string{"running", "suspended", "canceled"}, false)),
+			},
+			"wait": {
+				Description: "Wait for a running scrub and/or trim to complete using `zpool wait`. Defaults to `false`",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+			},
+			"triggers": {
+				Description: "Arbitrary map of values which, when changed, will start a new scrub.",
+				Type:        schema.TypeMap,
+				Optional:    true,
+				ForceNew:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+			},
+			"scrub_state": {
+				Description: "State of the last scan of the pool. One of `none`, `scanning`, `finished`, `canceled` or `paused`.",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+			"trim_state": {
+				Description: "State of the trim of the pool. One of `none`, `running`, `suspended` or `completed`.",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+			"last_scrub_time": {
+				Description: "Time at which the last scrub finished, as reported by `zpool status`.",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+			"scrub_repaired": {
+				Description: "Amount of data repaired by the last scrub.",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+			"scrub_errors": {
+				Description: "Number of errors encountered by the last scrub.",
+				Type:        schema.TypeInt,
+				Computed:    true,
+			},
+		},
+	}
+}
+
+func resourcePoolScrubCreate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	config := meta.(*Config)
+	poolName := d.Get("pool").(string)
+
+	pool, err := describePool(config, poolName, []string{})
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	if err := applyScrubState(config, d, poolName, d.Timeout(schema.TimeoutCreate)); err != nil {
+		return diag.FromErr(err)
+	}
+
+	log.Printf("[DEBUG] committing guid: %s", pool.guid)
+	d.SetId(pool.guid)
+
+	return resourcePoolScrubRead(ctx, d, meta)
+}
+
+func resourcePoolScrubRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	config := meta.(*Config)
+
+	poolName := d.Get("pool").(string)
+	real_name, err := getPoolNameByGuid(config, d.Id())
+	if err != nil {
+		return diag.FromErr(fmt.Errorf("the zpool %s identified by guid %s could not be found. It was likely deleted on the server outside of terraform", poolName, d.Id()))
+	}
+	poolName = *real_name
+
+	if err := d.Set("pool", poolName); err != nil {
+		return diag.FromErr(err)
+	}
+
+	status, err := readScanStatus(config, poolName)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	if err := d.Set("scrub_state", string(status.state)); err != nil {
+		return diag.FromErr(err)
+	}
+
+	trimState, err := readTrimState(config, poolName)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	if err := d.Set("trim_state", string(trimState)); err != nil {
+		return diag.FromErr(err)
+	}
+
+	// zpool status only reports the most recent scan, so if that was a resilver we keep whatever we knew about the
+	// last scrub.
+	if status.function == "scrub" && status.state == ScanFinished {
+		if err := d.Set("last_scrub_time", status.end); err != nil {
+			return diag.FromErr(err)
+		}
+		if err := d.Set("scrub_repaired", status.repaired); err != nil {
+			return diag.FromErr(err)
+		}
+		if err := d.Set("scrub_errors", status.errors); err != nil {
+			return diag.FromErr(err)
+		}
+	}
+
+	return diags
+}
+
+func resourcePoolScrubUpdate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	config := meta.(*Config)
+
+	real_name, err := getPoolNameByGuid(config, d.Id())
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	if err := applyScrubState(config, d, *real_name, d.Timeout(schema.TimeoutUpdate)); err != nil {
+		return diag.FromErr(err)
+	}
+
+	return resourcePoolScrubRead(ctx, d, meta)
+}
+
+func resourcePoolScrubDelete(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	// Scrubs and trims are left to finish on their own, there is nothing to clean up.
+	d.SetId("")
+
+	return diags
+}
+
+func applyScrubState(config *Config, d *schema.ResourceData, poolName string, timeout time.Duration) error {
+	status, err := readScanStatus(config, poolName)
+	if err != nil {
+		return err
+	}
+
+	scrubbing := status.function == "scrub" && status.state == ScanScanning
+	log.Printf("[DEBUG] scan status of %s: %v", poolName, status)
+
+	if d.HasChange("scrub") || d.IsNewResource() {
+		switch d.Get("scrub").(string) {
+		case "running":
+			// Starting a scrub also resumes a paused one.
+			if !scrubbing {
+				if err := startScrub(config, poolName); err != nil {
+					return err
+				}
+			}
+		case "paused":
+			if scrubbing {
+				if err := pauseScrub(config, poolName); err != nil {
+					return err
+				}
+			}
+		case "canceled":
+			if scrubbing || status.state == ScanPaused {
+				if err := cancelScrub(config, poolName); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	if d.HasChange("trim") || d.IsNewResource() {
+		// zpool complains about trims which are already running, or which aren't there to suspend or cancel.
+		trimState, err := readTrimState(config, poolName)
+		if err != nil {
+			return err
+		}
+		log.Printf("[DEBUG] trim state of %s: %s", poolName, trimState)
+
+		switch d.Get("trim").(string) {
+		case "running":
+			// Starting a trim also resumes a suspended one.
+			if trimState != TrimRunning {
+				if err := startTrim(config, poolName); err != nil {
+					return err
+				}
+			}
+		case "suspended":
+			if trimState == TrimRunning {
+				if err := suspendTrim(config, poolName); err != nil {
+					return err
+				}
+			}
+		case "canceled":
+			if trimState == TrimRunning || trimState == TrimSuspended {
+				if err := cancelTrim(config, poolName); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	if d.Get("wait").(bool) {
+		if d.Get("scrub").(string) == "running" {
+			if err := waitForPoolActivity(config, poolName, "scrub", timeout); err != nil {
+				return err
+			}
+		}
+		if d.Get("trim").(string) == "running" {
+			if err := waitForPoolActivity(config, poolName, "trim", timeout); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,83 @@
+package provider
+
+import (
+	"testing"
+)
+
+// TestParseScanStatus_States verifies that each of the scan line formats
+// printed by `zpool status` is mapped to the correct scan state.
+func TestParseScanStatus_States(t *testing.T) {
+	cases := map[string]ScanStatus{
+		"  scan: none requested": {state: ScanNone},
+		"  scan: scrub repaired 0B in 00:00:01 with 0 errors on Sun Oct 18 00:24:01 2026": {
+			function: "scrub", state: ScanFinished, repaired: "0B", errors: 0, end: "Sun Oct 18 00:24:01 2026",
+		},
+		"  scan: scrub repaired 12K in 0 days 02:00:01 with 3 errors on Sun Oct 18 00:24:01 2026": {
+			function: "scrub", state: ScanFinished, repaired: "12K", errors: 3, end: "Sun Oct 18 00:24:01 2026",
+		},
+		"  scan: resilvered 1.21G in 00:01:00 with 0 errors on Sun Oct 18 00:24:01 2026": {
+			function: "resilver", state: ScanFinished, repaired: "1.21G", errors: 0, end: "Sun Oct 18 00:24:01 2026",
+		},
+		"  scan: scrub in progress since Sun Oct 18 00:24:01 2026": {
+			function: "scrub", state: ScanScanning, start: "Sun Oct 18 00:24:01 2026",
+		},
+		"  scan: scrub canceled on Sun Oct 18 00:24:01 2026": {
+			function: "scrub", state: ScanCanceled, end: "Sun Oct 18 00:24:01 2026",
+		},
+		"  scan: scrub paused since Sun Oct 18 00:24:01 2026": {
+			function: "scrub", state: ScanPaused, start: "Sun Oct 18 00:24:01 2026",
+		},
+	}
+
+	for line, expected := range cases {
+		stdout := "  pool: aquarium\n state: ONLINE\n" + line + "\nconfig:\n"
+		status, err := parseScanStatus(stdout)
+		if err != nil {
+			t.Fatalf("unexpected error parsing %q: %v", line, err)
+		}
+		if *status != expected {
+			t.Fatalf("parsing %q: expected %#v, got %#v", line, expected, *status)
+		}
+	}
+}
+
+// TestParseScanStatus_MissingScanLine verifies that a pool without any
+// scan history is reported as never having been scanned.
+func TestParseScanStatus_MissingScanLine(t *testing.T) {
+	status, err := parseScanStatus("  pool: aquarium\n state: ONLINE\nconfig:\n")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if status.state != ScanNone {
+		t.Fatalf("expected state %q, got %q", ScanNone, status.state)
+	}
+}
+
+// TestParseScanStatus_Unrecognized verifies that unknown scan formats
+// are reported as errors rather than silently ignored.
+func TestParseScanStatus_Unrecognized(t *testing.T) {
+	if _, err := parseScanStatus("  scan: something unexpected"); err == nil {
+		t.Fatalf("expected an error for an unrecognized scan line")
+	}
+}
+
+// TestParseTrimStatus verifies that the trim state of the pool is derived
+// from the trim status of its devices.
+func TestParseTrimStatus(t *testing.T) {
+	header := "  pool: aquarium\n state: ONLINE\nconfig:\n\n\tNAME        STATE     READ WRITE CKSUM\n\taquarium    ONLINE       0     0     0\n"
+	cases := map[string]TrimState{
+		"\t  sda       ONLINE       0     0     0  (untrimmed)\n":                                                   TrimNone,
+		"\t  sda       ONLINE       0     0     0  (trim unsupported)\n":                                            TrimNone,
+		"\t  sda       ONLINE       0     0     0\n":                                                                TrimNone,
+		"\t  sda       ONLINE       0     0     0  (100% trimmed, completed at Sun Oct 18 00:24:01 2026)\n":         TrimCompleted,
+		"\t  sda       ONLINE       0     0     0  (12% trimmed, suspended, started at Sun Oct 18 00:24:01 2026)\n": TrimSuspended,
+		"\t  sda       ONLINE       0     0     0  (100% trimmed, completed at Sun Oct 18 00:24:01 2026)\n" +
+			"\t  sdb       ONLINE       0     0     0  (12% trimmed, started at Sun Oct 18 00:24:01 2026)\n": TrimRunning,
+	}
+
+	for devices, expected := range cases {
+		if state := parseTrimStatus(header + devices + "\nerrors: No known data errors\n"); state != expected {
+			t.Fatalf("parsing %q: expected %s, got %s", devices, expected, state)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func resourcePoolSplit() *schema.Resource {
+	return &schema.Resource{
+		// This description is used by the documentation generator and the language server.
+		Description: "Splits a mirrored zpool into a new pool using `zpool split`, taking one device from each mirror. If the new pool is imported, it is exported again when the resource is destroyed, otherwise destroying the resource leaves the new pool alone. If the split pool is managed by a `zfs_pool` resource, its `mirror` blocks have to be replaced with `device` blocks for the devices left behind, as the pool is refused until then rather than recreated.",
+
+		CreateContext: resourcePoolSplitCreate,
+		ReadContext:   resourcePoolSplitRead,
+		DeleteContext: resourcePoolSplitDelete,
+
+		Schema: map[string]*schema.Schema{
+			"pool": {
+				Description: "Name of the mirrored zpool to split.",
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+			},
+			"new_pool": {
+				Description: "Name of the new zpool created from the split off devices.",
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+			},
+			"devices": {
+				Description: "Devices to split off into the new pool, at most one per mirror. By default the last device of each mirror is used.",
+				Type:        schema.TypeList,
+				Optional:    true,
+				ForceNew:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+			},
+			"altroot": {
+				Description: "Import the new pool under this alternate root. If not set, the new pool is left exported.",
+				Type:        schema.TypeString,
+				Optional:    true,
+				ForceNew:    true,
+			},
+			"guid": {
+				Description: "Guid of the new zpool.",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+		},
+	}
+}
+
+func resourcePoolSplitCreate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	config := meta.(*Config)
+
+	poolName := d.Get("pool").(string)
+	newPoolName := d.Get("new_pool").(string)
+
+	devices := make([]string, 0)
+	for _, device := range d.Get("devices").([]interface{}) {
+		devices = append(devices, device.(string))
+	}
+
+	layout, err := readPoolLayout(config, poolName)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	if err := validatePoolSplit(layout, devices); err != nil {
+		return diag.FromErr(err)
+	}
+
+	altroot := d.Get("altroot").(string)
+	if err := splitPool(config, &SplitPool{
+		name:    poolName,
+		newName: newPoolName,
+		altroot: altroot,
+		devices: devices,
+	}); err != nil {
+		return diag.FromErr(err)
+	}
+
+	var guid string
+	if altroot != "" {
+		pool, err := describePool(config, newPoolName, []string{})
+		if err != nil {
+			return diag.FromErr(err)
+		}
+		guid = pool.guid
+	} else {
+		pools, err := listImportablePools(config)
+		if err != nil {
+			return diag.FromErr(err)
+		}
+		var ok bool
+		if guid, ok = pools[newPoolName]; !ok {
+			return diag.FromErr(fmt.Errorf("zpool %s was split off from %s, but could not be found among the importable pools", newPoolName, poolName))
+		}
+	}
+
+	log.Printf("[DEBUG] committing guid: %s", guid)
+	d.SetId(guid)
+
+	return resourcePoolSplitRead(ctx, d, meta)
+}
+
+func resourcePoolSplitRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	config := meta.(*Config)
+
+	if err := d.Set("guid", d.Id()); err != nil {
+		return diag.FromErr(err)
+	}
+
+	// The new pool may either be imported or still be sitting on its devices, exported.
+	if _, err := getPoolNameByGuid(config, d.Id()); err == nil {
+		return diags
+	}
+
+	pools, err := listImportablePools(config)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	for _, guid := range pools {
+		if guid == d.Id() {
+			return diags
+		}
+	}
+
+	log.Printf("[DEBUG] split off zpool %s with guid %s no longer exists", d.Get("new_pool").(string), d.Id())
+	d.SetId("")
+	return diags
+}
+
+func resourcePoolSplitDelete(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	config := meta.(*Config)
+
+	if poolName, err := getPoolNameByGuid(config, d.Id()); err == nil && d.Get("altroot").(string) != "" {
+		log.Printf("[DEBUG] exporting split off pool: %s", *poolName)
+		if err := exportPool(config, *poolName); err != nil {
+			return diag.FromErr(err)
+		}
+	}
+
+	d.SetId("")
+	return diags
+}
@@ -0,0 +1,90 @@
+package provider
+
+import (
+	"testing"
+)
+
+// TestValidatePoolSplit_RequiresMirrors verifies that pools containing
+// anything other than mirrors are refused.
+func TestValidatePoolSplit_RequiresMirrors(t *testing.T) {
+	layouts := []PoolLayout{
+		{},
+		{striped: []Device{{path: "/dev/sda"}}},
+		{raidz: []Raidz{{name: "raidz1-0", parity: 1, devices: []Device{{path: "/dev/sda"}, {path: "/dev/sdb"}}}}},
+		{
+			mirrors: []Mirror{{name: "mirror-0", devices: []Device{{path: "/dev/sda"}, {path: "/dev/sdb"}}}},
+			striped: []Device{{path: "/dev/sdc"}},
+		},
+	}
+
+	for _, layout := range layouts {
+		if err := validatePoolSplit(&layout, []string{}); err == nil {
+			t.Fatalf("expected an error splitting %#v", layout)
+		}
+	}
+}
+
+// TestValidatePoolSplit_Devices verifies that split off devices must
+// belong to separate mirrors of the pool.
+func TestValidatePoolSplit_Devices(t *testing.T) {
+	layout := PoolLayout{
+		mirrors: []Mirror{
+			{name: "mirror-0", devices: []Device{{path: "/dev/sda1"}, {path: "/dev/sdb1"}}},
+			{name: "mirror-1", devices: []Device{{path: "/dev/sdc1"}, {path: "/dev/sdd1"}}},
+		},
+	}
+
+	if err := validatePoolSplit(&layout, []string{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := validatePoolSplit(&layout, []string{"/dev/sdb", "/dev/sdc"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := validatePoolSplit(&layout, []string{"/dev/sda", "/dev/sdb"}); err == nil {
+		t.Fatalf("expected an error splitting two devices from the same mirror")
+	}
+	if err := validatePoolSplit(&layout, []string{"/dev/sde"}); err == nil {
+		t.Fatalf("expected an error splitting a device outside the pool")
+	}
+}
+
+// TestParseImportablePools verifies that pool names and guids are read
+// from the output of `zpool import`.
+func TestParseImportablePools(t *testing.T) {
+	stdout := `   pool: zdata-backup
+     id: 8127361920184756
+  state: ONLINE
+ action: The pool can be imported using its name or numeric identifier.
+ config:
+
+	zdata-backup  ONLINE
+	  sdb         ONLINE
+
+   pool: old
+     id: 1234
+  state: ONLINE
+`
+	pools := parseImportablePools(stdout)
+	if len(pools) != 2 || pools["zdata-backup"] != "8127361920184756" || pools["old"] != "1234" {
+		t.Fatalf("unexpected pools: %v", pools)
+	}
+}
+
+// TestFindSplitDevice verifies that a zfs_pool whose mirrors were split is
+// recognized, instead of being planned for recreation.
+func TestFindSplitDevice(t *testing.T) {
+	device := func(path string) map[string]interface{} {
+		return map[string]interface{}{"path": path, "replaced_by": "", "by_id": "", "serial": "", "wwn": ""}
+	}
+	oldDevices := []interface{}{device("/dev/sda1"), device("/dev/sdc1")}
+
+	split := []interface{}{map[string]interface{}{"device": []interface{}{device("/dev/sda1"), device("/dev/sdb1")}}}
+	if path := findSplitDevice(oldDevices, split); path != "/dev/sda1" {
+		t.Fatalf("expected /dev/sda1 to be found, got %q", path)
+	}
+
+	added := []interface{}{map[string]interface{}{"device": []interface{}{device("/dev/sde1"), device("/dev/sdf1")}}}
+	if path := findSplitDevice(oldDevices, added); path != "" {
+		t.Fatalf("expected no split device, got %q", path)
+	}
+}
@@ -1,6 +1,7 @@
 package provider
 
 import (
+	"reflect"
 	"testing"
 
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
@@ -307,3 +308,547 @@ func TestIsPoolProperty_RecognizesKnownAndFeatureProps(t *testing.T) {
 		}
 	}
 }
+
+// TestPopulateResourceDataPool_Features verifies that feature states are
+// exposed, and that desired features which are still disabled on the pool
+// are left out of state so they show up as a diff.
+func TestPopulateResourceDataPool_Features(t *testing.T) {
+	rd := buildResourceDataPool(t)
+
+	if err := rd.Set("features", []interface{}{"encryption", "draid"}); err != nil {
+		t.Fatalf("failed to set features: %v", err)
+	}
+	if err := rd.Set("upgrade", true); err != nil {
+		t.Fatalf("failed to set upgrade: %v", err)
+	}
+
+	pool := Pool{
+		guid: "pool-guid-123",
+		properties: map[string]Property{
+			"compatibility":      {source: SourceDefault, value: "off", rawValue: "off"},
+			"feature@encryption": {source: SourceLocal, value: "active", rawValue: "active"},
+			"feature@draid":      {source: SourceLocal, value: "disabled", rawValue: "disabled"},
+		},
+	}
+
+	diags := populateResourceDataPool(rd, pool)
+	if diags.HasError() {
+		t.Fatalf("unexpected diagnostics: %#v", diags)
+	}
+
+	states := rd.Get("feature_states").(map[string]interface{})
+	if states["encryption"] != "active" || states["draid"] != "disabled" {
+		t.Fatalf("unexpected feature states: %#v", states)
+	}
+
+	features := rd.Get("features").(*schema.Set)
+	if features.Len() != 1 || !features.Contains("encryption") {
+		t.Fatalf("expected only encryption to be reported as enabled, got %#v", features.List())
+	}
+
+	if rd.Get("upgrade").(bool) {
+		t.Fatalf("expected upgrade to be reported as false while features are disabled")
+	}
+
+	if rd.Get("compatibility") != "off" {
+		t.Fatalf("expected compatibility off, got %q", rd.Get("compatibility"))
+	}
+}
+
+// TestPopulateResourceDataPool_UpgradeWithCompatibility verifies that
+// features left disabled by the compatibility feature sets don't report the
+// pool as needing an upgrade.
+func TestPopulateResourceDataPool_UpgradeWithCompatibility(t *testing.T) {
+	rd := buildResourceDataPool(t)
+
+	if err := rd.Set("upgrade", true); err != nil {
+		t.Fatalf("failed to set upgrade: %v", err)
+	}
+
+	pool := Pool{
+		guid: "pool-guid-123",
+		properties: map[string]Property{
+			"compatibility":           {source: SourceLocal, value: "openzfs-2.1-linux", rawValue: "openzfs-2.1-linux"},
+			"feature@encryption":      {source: SourceLocal, value: "active", rawValue: "active"},
+			"feature@raidz_expansion": {source: SourceLocal, value: "disabled", rawValue: "disabled"},
+		},
+		compatibleFeatures: map[string]bool{"encryption": true},
+	}
+
+	diags := populateResourceDataPool(rd, pool)
+	if diags.HasError() {
+		t.Fatalf("unexpected diagnostics: %#v", diags)
+	}
+	if !rd.Get("upgrade").(bool) {
+		t.Fatalf("expected upgrade to be kept while only incompatible features are disabled")
+	}
+
+	pool.compatibleFeatures["raidz_expansion"] = true
+	if diags := populateResourceDataPool(rd, pool); diags.HasError() {
+		t.Fatalf("unexpected diagnostics: %#v", diags)
+	}
+	if rd.Get("upgrade").(bool) {
+		t.Fatalf("expected upgrade to be reported as false while compatible features are disabled")
+	}
+}
+
+// TestParseCompatibilityFile verifies that features are read from
+// compatibility feature set files, and that only the features listed in
+// every file are allowed.
+func TestParseCompatibilityFile(t *testing.T) {
+	first := parseCompatibilityFile("# features supported by OpenZFS 2.0 on Linux\nasync_destroy\nbookmarks, embedded_data\n\nencryption  # since 0.8\n")
+	if !reflect.DeepEqual(first, []string{"async_destroy", "bookmarks", "embedded_data", "encryption"}) {
+		t.Fatalf("unexpected features: %v", first)
+	}
+
+	compatible := intersectFeatures([][]string{first, {"bookmarks", "encryption", "draid"}})
+	if !reflect.DeepEqual(compatible, map[string]bool{"bookmarks": true, "encryption": true}) {
+		t.Fatalf("unexpected compatible features: %v", compatible)
+	}
+}
+
+// TestNormalizeDevicePath verifies that partitions created by zfs on whole
+// disks are mapped back to the disk itself.
+func TestNormalizeDevicePath(t *testing.T) {
+	cases := map[string]string{
+		"/dev/sda":                          "/dev/sda",
+		"/dev/sda1":                         "/dev/sda",
+		"/dev/vdb9":                         "/dev/vdb",
+		"/dev/nvme0n1":                      "/dev/nvme0n1",
+		"/dev/nvme0n1p1":                    "/dev/nvme0n1",
+		"/dev/disk/by-id/wwn-0x5000c500a1":  "/dev/disk/by-id/wwn-0x5000c500a1",
+		"/dev/disk/by-id/ata-DISK_S3-part1": "/dev/disk/by-id/ata-DISK_S3",
+		"/tmp/vdev-file":                    "/tmp/vdev-file",
+	}
+
+	for path, expected := range cases {
+		if got := normalizeDevicePath(path); got != expected {
+			t.Fatalf("normalizeDevicePath(%q): expected %q, got %q", path, expected, got)
+		}
+	}
+}
+
+// TestFindDiskBySerial verifies that serial numbers are resolved to the
+// by-id entry of the whole disk, ignoring partitions and wwn aliases.
+func TestFindDiskBySerial(t *testing.T) {
+	entries := []string{
+		"ata-Samsung_SSD_860_EVO_500GB_S3Z9NB0K123456-part1",
+		"ata-Samsung_SSD_860_EVO_500GB_S3Z9NB0K123456",
+		"nvme-INTEL_SSDPEKNW010T8_BTNH9876",
+		"wwn-0x5002538e40a1b2c3",
+	}
+
+	name, err := findDiskBySerial(entries, "S3Z9NB0K123456")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if name != "ata-Samsung_SSD_860_EVO_500GB_S3Z9NB0K123456" {
+		t.Fatalf("unexpected disk: %q", name)
+	}
+
+	if _, err := findDiskBySerial(entries, "DOESNOTEXIST"); err == nil {
+		t.Fatalf("expected an error for an unknown serial")
+	}
+}
+
+// TestPopulateResourceDataPool_KeepsWholeDiskPaths verifies that a pool
+// configured with whole disks doesn't drift when zpool reports the
+// partitions created on them.
+func TestPopulateResourceDataPool_KeepsWholeDiskPaths(t *testing.T) {
+	rd := buildResourceDataPool(t)
+
+	if err := rd.Set("device", []interface{}{
+		map[string]interface{}{"path": "/dev/disk/by-id/wwn-0x5000c500a1", "wwn": "0x5000c500a1"},
+		map[string]interface{}{"path": "/dev/sdb"},
+	}); err != nil {
+		t.Fatalf("failed to set device: %v", err)
+	}
+
+	pool := Pool{
+		guid: "pool-guid-123",
+		layout: PoolLayout{
+			striped: []Device{
+				{path: "/dev/disk/by-id/wwn-0x5000c500a1-part1"},
+				{path: "/dev/sdc1"},
+			},
+		},
+		properties: map[string]Property{},
+	}
+
+	diags := populateResourceDataPool(rd, pool)
+	if diags.HasError() {
+		t.Fatalf("unexpected diagnostics: %#v", diags)
+	}
+
+	devices := rd.Get("device").([]interface{})
+	first := devices[0].(map[string]interface{})
+	if first["path"] != "/dev/disk/by-id/wwn-0x5000c500a1" || first["wwn"] != "0x5000c500a1" {
+		t.Fatalf("expected configured wwn device to be kept, got %#v", first)
+	}
+
+	second := devices[1].(map[string]interface{})
+	if second["path"] != "/dev/sdc1" {
+		t.Fatalf("expected a different disk to be reported as drift, got %#v", second)
+	}
+}
+
+// TestParseDevicesInUse verifies that devices listed by zpool list are
+// attributed to the pool they belong to.
+func TestParseDevicesInUse(t *testing.T) {
+	stdout := "aquarium\t9.50G\t110K\t9.50G\t-\t-\t0%\t0%\t1.00x\tONLINE\t-\n" +
+		"\tmirror-0\t9.50G\t110K\t9.50G\t-\t-\t0%\t0.00%\t-\tONLINE\n" +
+		"\t/dev/sda1\t-\t-\t-\t-\t-\t-\t-\t-\tONLINE\n" +
+		"\t/dev/sdb1\t-\t-\t-\t-\t-\t-\t-\t-\tONLINE\n" +
+		"boot\t960M\t110K\t960M\t-\t-\t0%\t0%\t1.00x\tONLINE\t-\n" +
+		"\t/dev/nvme0n1p2\t960M\t110K\t960M\t-\t-\t0%\t0.00%\t-\tONLINE"
+
+	devices := parseDevicesInUse(stdout)
+	if len(devices) != 3 {
+		t.Fatalf("expected 3 devices, got %#v", devices)
+	}
+	if devices["/dev/sda1"] != "aquarium" || devices["/dev/sdb1"] != "aquarium" || devices["/dev/nvme0n1p2"] != "boot" {
+		t.Fatalf("unexpected devices: %#v", devices)
+	}
+}
+
+// TestFindPoolUsingDevice verifies that disks selected through
+// /dev/disk/by-id are found in pools listing them by their device node.
+func TestFindPoolUsingDevice(t *testing.T) {
+	devices := map[string]string{
+		"/dev/sda1":      "aquarium",
+		"/dev/nvme0n1p2": "boot",
+	}
+	canonical := map[string]string{
+		"/dev/sda1":      "/dev/sda1",
+		"/dev/nvme0n1p2": "/dev/nvme0n1p2",
+		"/dev/disk/by-id/ata-Samsung_SSD_860_EVO_500GB_S3Z9NB0K123456": "/dev/sda",
+		"/dev/disk/by-id/nvme-eui.0025388b71b2c3d4-part2":              "/dev/nvme0n1p2",
+		"/dev/disk/by-id/wwn-0x5000c500a1b2c3d4":                       "/dev/sdc",
+	}
+
+	cases := map[string]string{
+		"/dev/sda": "aquarium",
+		"/dev/disk/by-id/ata-Samsung_SSD_860_EVO_500GB_S3Z9NB0K123456": "aquarium",
+		"/dev/disk/by-id/nvme-eui.0025388b71b2c3d4-part2":              "boot",
+		"/dev/disk/by-id/wwn-0x5000c500a1b2c3d4":                       "",
+	}
+	for path, expected := range cases {
+		if poolName := findPoolUsingDevice(path, devices, canonical); poolName != expected {
+			t.Fatalf("expected %s to be used by %q, got %q", path, expected, poolName)
+		}
+	}
+}
+
+// TestHasZfsMember verifies that zfs labels on a disk or any of its
+// partitions are detected in the output of lsblk.
+func TestHasZfsMember(t *testing.T) {
+	cases := map[string]bool{
+		"":                         false,
+		"\next4\n":                 false,
+		"zfs_member":               true,
+		"\nzfs_member\nzfs_member": true,
+		"\nvfat\nzfs_member":       true,
+	}
+	for stdout, expected := range cases {
+		if member := hasZfsMember(stdout); member != expected {
+			t.Fatalf("expected %q to be a zfs member: %v", stdout, expected)
+		}
+	}
+}
+
+// TestParseBlockDevices verifies that the disk and its partitions are read
+// from lsblk, so that the labels on all of them are cleared.
+func TestParseBlockDevices(t *testing.T) {
+	devices := parseBlockDevices("/dev/sdb\n/dev/sdb1\n/dev/sdb9\n")
+	if !reflect.DeepEqual(devices, []string{"/dev/sdb", "/dev/sdb1", "/dev/sdb9"}) {
+		t.Fatalf("unexpected devices: %v", devices)
+	}
+	if devices := parseBlockDevices(""); len(devices) != 0 {
+		t.Fatalf("expected no devices, got %v", devices)
+	}
+}
+
+// TestGetVdevPath_Partition verifies that the partition path is handed to
+// zpool when a partition block is defined.
+func TestGetVdevPath_Partition(t *testing.T) {
+	cases := map[string]string{
+		"/dev/sda":                    "/dev/sda2",
+		"/dev/nvme0n1":                "/dev/nvme0n1p2",
+		"/dev/disk/by-id/ata-DISK_S3": "/dev/disk/by-id/ata-DISK_S3-part2",
+	}
+
+	for path, expected := range cases {
+		device := map[string]interface{}{
+			"path":      path,
+			"partition": []interface{}{map[string]interface{}{"number": 2, "size": "1G", "type_code": "BF01"}},
+		}
+		if got := getVdevPath(device); got != expected {
+			t.Fatalf("getVdevPath(%q): expected %q, got %q", path, expected, got)
+		}
+	}
+
+	if got := getVdevPath(map[string]interface{}{"path": "/dev/sda"}); got != "/dev/sda" {
+		t.Fatalf("expected whole disk path without partition, got %q", got)
+	}
+}
+
+// TestMergeDevices_ReplacedAndOffline verifies that replaced devices are
+// tracked by their replacement, and that offline devices are reported.
+func TestMergeDevices_ReplacedAndOffline(t *testing.T) {
+	configured := []interface{}{
+		map[string]interface{}{"path": "/dev/sda", "replaced_by": "/dev/sdx", "offline": false},
+		map[string]interface{}{"path": "/dev/sdb", "replaced_by": "", "offline": false},
+	}
+	actual := []Device{
+		{path: "/dev/sdx1", state: "ONLINE"},
+		{path: "/dev/sdb1", state: "OFFLINE"},
+	}
+
+	devices := mergeDevices(configured, actual)
+
+	if devices[0]["path"] != "/dev/sdx" || devices[0]["replaced_by"] != "/dev/sdx" {
+		t.Fatalf("expected replaced device to be tracked by its replacement, got %#v", devices[0])
+	}
+	if devices[1]["path"] != "/dev/sdb" || devices[1]["offline"] != true {
+		t.Fatalf("expected offline device to be reported as offline, got %#v", devices[1])
+	}
+}
+
+// TestMergeDevices_Selector verifies that devices selected by by_id keep
+// their selector once the resolved path is stored next to it, even though
+// zpool reports the partition it created on the disk.
+func TestMergeDevices_Selector(t *testing.T) {
+	configured := []interface{}{
+		map[string]interface{}{"path": "/dev/disk/by-id/ata-Samsung_SSD_860_EVO_500GB_S3Z9NB0K123456", "by_id": "ata-Samsung_SSD_860_EVO_500GB_S3Z9NB0K123456", "replaced_by": "", "offline": false},
+	}
+	actual := []Device{
+		{path: "/dev/disk/by-id/ata-Samsung_SSD_860_EVO_500GB_S3Z9NB0K123456-part1", state: "ONLINE"},
+	}
+
+	devices := mergeDevices(configured, actual)
+
+	if devices[0]["by_id"] != "ata-Samsung_SSD_860_EVO_500GB_S3Z9NB0K123456" || devices[0]["path"] != "/dev/disk/by-id/ata-Samsung_SSD_860_EVO_500GB_S3Z9NB0K123456" {
+		t.Fatalf("expected the selector and resolved path to be kept, got %#v", devices[0])
+	}
+}
+
+// TestParsePoolLayout_Raidz verifies that raidz vdevs are recognized along
+// with their parity, and that devices are attributed to the right vdev.
+func TestParsePoolLayout_Raidz(t *testing.T) {
+	stdout := "aquarium\t29.5G\t110K\t29.5G\t-\t-\t0%\t0%\t1.00x\tONLINE\t-\n" +
+		"\traidz2-0\t19.5G\t110K\t19.5G\t-\t-\t0%\t0.00%\t-\tONLINE\n" +
+		"\t/dev/sda1\t-\t-\t-\t-\t-\t-\t-\t-\tONLINE\n" +
+		"\t/dev/sdb1\t-\t-\t-\t-\t-\t-\t-\t-\tONLINE\n" +
+		"\t/dev/sdc1\t-\t-\t-\t-\t-\t-\t-\t-\tOFFLINE\n" +
+		"\tmirror-1\t9.50G\t0\t9.50G\t-\t-\t0%\t0.00%\t-\tONLINE\n" +
+		"\t/dev/sdd1\t-\t-\t-\t-\t-\t-\t-\t-\tONLINE\n" +
+		"\t/dev/sde1\t-\t-\t-\t-\t-\t-\t-\t-\tONLINE"
+
+	layout, err := parsePoolLayout(stdout)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(layout.striped) != 0 || len(layout.raidz) != 1 || len(layout.mirrors) != 1 {
+		t.Fatalf("unexpected layout: %#v", layout)
+	}
+
+	raidz := layout.raidz[0]
+	if raidz.name != "raidz2-0" || raidz.parity != 2 || len(raidz.devices) != 3 {
+		t.Fatalf("unexpected raidz vdev: %#v", raidz)
+	}
+	if raidz.devices[2].state != "OFFLINE" {
+		t.Fatalf("expected /dev/sdc1 to be offline, got %#v", raidz.devices[2])
+	}
+
+	if layout.mirrors[0].name != "mirror-1" || len(layout.mirrors[0].devices) != 2 {
+		t.Fatalf("unexpected mirror vdev: %#v", layout.mirrors[0])
+	}
+}
+
+// TestParsePoolLayout_Replacing verifies that devices being replaced, or
+// replaced by a hot spare, are reported as the original device rather than
+// as separate devices.
+func TestParsePoolLayout_Replacing(t *testing.T) {
+	stdout := "aquarium\t19.0G\t110K\t19.0G\t-\t-\t0%\t0%\t1.00x\tDEGRADED\t-\n" +
+		"\tmirror-0\t9.50G\t110K\t9.50G\t-\t-\t0%\t0.00%\t-\tONLINE\n" +
+		"\treplacing-0\t-\t-\t-\t-\t-\t-\t-\t-\tONLINE\n" +
+		"\t/dev/sda1\t-\t-\t-\t-\t-\t-\t-\t-\tONLINE\n" +
+		"\t/dev/sdx1\t-\t-\t-\t-\t-\t-\t-\t-\tONLINE\n" +
+		"\t/dev/sdb1\t-\t-\t-\t-\t-\t-\t-\t-\tONLINE\n" +
+		"\tmirror-1\t9.50G\t110K\t9.50G\t-\t-\t0%\t0.00%\t-\tDEGRADED\n" +
+		"\t/dev/sdc1\t-\t-\t-\t-\t-\t-\t-\t-\tONLINE\n" +
+		"\tspare-1\t-\t-\t-\t-\t-\t-\t-\t-\tDEGRADED\n" +
+		"\t/dev/sdd1\t-\t-\t-\t-\t-\t-\t-\t-\tFAULTED\n" +
+		"\t/dev/sdz1\t-\t-\t-\t-\t-\t-\t-\t-\tONLINE\n" +
+		"spare\t-\t-\t-\t-\t-\t-\t-\t-\t-\n" +
+		"\t/dev/sdz1\t-\t-\t-\t-\t-\t-\t-\t-\tINUSE"
+
+	layout, err := parsePoolLayout(stdout)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(layout.mirrors) != 2 || len(layout.striped) != 0 {
+		t.Fatalf("unexpected layout: %#v", layout)
+	}
+	if devices := layout.mirrors[0].devices; len(devices) != 2 || devices[0].path != "/dev/sda1" || devices[1].path != "/dev/sdb1" {
+		t.Fatalf("expected the device being replaced to be reported, got %#v", devices)
+	}
+	if devices := layout.mirrors[1].devices; len(devices) != 2 || devices[0].path != "/dev/sdc1" || devices[1].path != "/dev/sdd1" {
+		t.Fatalf("expected the device replaced by a spare to be reported, got %#v", devices)
+	}
+	if len(layout.spares) != 1 || layout.spares[0].path != "/dev/sdz1" {
+		t.Fatalf("unexpected spares: %#v", layout.spares)
+	}
+}
+
+// TestParseVdevSpecification_Raidz verifies that raidz blocks are turned
+// into raidzN vdev specifications for zpool create.
+func TestParseVdevSpecification_Raidz(t *testing.T) {
+	raidz := []interface{}{
+		map[string]interface{}{
+			"parity": 2,
+			"device": []interface{}{
+				map[string]interface{}{"path": "/dev/sda"},
+				map[string]interface{}{"path": "/dev/sdb"},
+				map[string]interface{}{"path": "/dev/sdc"},
+			},
+		},
+	}
+
+	if got := parseVdevSpecification(nil, raidz, nil); got != " raidz2 /dev/sda /dev/sdb /dev/sdc" {
+		t.Fatalf("unexpected vdev specification: %q", got)
+	}
+}
+
+// TestParseExpansionStatus verifies that the progress of a running raidz
+// expansion is picked up from zpool status.
+func TestParseExpansionStatus(t *testing.T) {
+	stdout := "  pool: aquarium\n state: ONLINE\nexpand: expansion of raidz1-0 in progress since Sun Oct 18 00:24:01 2026\n" +
+		"\t1.07G / 1.57G copied at 63.4M/s, 68.33% done, 00:00:08 to go\nconfig:\n"
+
+	expanding, progress, err := parseExpansionStatus(stdout)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !expanding || progress != 68.33 {
+		t.Fatalf("expected expansion at 68.33%%, got %v at %v", expanding, progress)
+	}
+
+	expanding, _, err = parseExpansionStatus("  pool: aquarium\n state: ONLINE\nexpand: expanded raidz1-0-0 copied 1.57G in 00:00:25, on Sun Oct 18 00:24:01 2026\n")
+	if err != nil || expanding {
+		t.Fatalf("expected finished expansion, got %v (%v)", expanding, err)
+	}
+}
+
+// TestParseZfsVersion verifies that the lowest of the userland and kernel
+// module versions is used.
+func TestParseZfsVersion(t *testing.T) {
+	version, err := parseZfsVersion("zfs-2.3.1-1\nzfs-kmod-2.2.6-1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if version.String() != "2.2.6" {
+		t.Fatalf("expected 2.2.6, got %s", version)
+	}
+	if version.atLeast(2, 3, 0) {
+		t.Fatalf("expected 2.2.6 to be older than 2.3.0")
+	}
+	if !version.atLeast(2, 2, 0) || !version.atLeast(0, 8, 6) {
+		t.Fatalf("expected 2.2.6 to be at least 2.2.0 and 0.8.6")
+	}
+
+	if _, err := parseZfsVersion("zfs: command not found"); err == nil {
+		t.Fatalf("expected an error for unrecognized version output")
+	}
+}
+
+// TestParsePoolLayout_AuxiliaryDevices verifies that log, cache and spare
+// devices are not mistaken for striped data devices.
+func TestParsePoolLayout_AuxiliaryDevices(t *testing.T) {
+	stdout := "aquarium\t9.50G\t110K\t9.50G\t-\t-\t0%\t0%\t1.00x\tONLINE\t-\n" +
+		"\t/dev/sda1\t9.50G\t110K\t9.50G\t-\t-\t0%\t0.00%\t-\tONLINE\n" +
+		"logs\t-\t-\t-\t-\t-\t-\t-\t-\t-\n" +
+		"\t/dev/sdb1\t960M\t0\t960M\t-\t-\t0%\t0.00%\t-\tONLINE\n" +
+		"cache\t-\t-\t-\t-\t-\t-\t-\t-\t-\n" +
+		"\t/dev/sdc1\t960M\t0\t960M\t-\t-\t0%\t0.00%\t-\tONLINE\n" +
+		"spare\t-\t-\t-\t-\t-\t-\t-\t-\t-\n" +
+		"\t/dev/sdd1\t-\t-\t-\t-\t-\t-\t-\t-\tAVAIL"
+
+	layout, err := parsePoolLayout(stdout)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(layout.striped) != 1 || layout.striped[0].path != "/dev/sda1" {
+		t.Fatalf("unexpected striped devices: %#v", layout.striped)
+	}
+	if len(layout.logs) != 1 || layout.logs[0].path != "/dev/sdb1" {
+		t.Fatalf("unexpected log devices: %#v", layout.logs)
+	}
+	if len(layout.cache) != 1 || layout.cache[0].path != "/dev/sdc1" {
+		t.Fatalf("unexpected cache devices: %#v", layout.cache)
+	}
+	if len(layout.spares) != 1 || layout.spares[0].path != "/dev/sdd1" || layout.spares[0].state != "AVAIL" {
+		t.Fatalf("unexpected spare devices: %#v", layout.spares)
+	}
+}
+
+// TestDiffVdevBlocks verifies that removed and added vdevs are detected
+// regardless of their position in the list.
+func TestDiffVdevBlocks(t *testing.T) {
+	old := []interface{}{
+		map[string]interface{}{"path": "/dev/sda"},
+		map[string]interface{}{"path": "/dev/sdb"},
+		map[string]interface{}{"path": "/dev/disk/by-id/wwn-0x5000c500a1", "wwn": "0x5000c500a1"},
+	}
+	new := []interface{}{
+		map[string]interface{}{"path": "/dev/sdb"},
+		map[string]interface{}{"path": "", "wwn": "0x5000c500a1"},
+		map[string]interface{}{"path": "/dev/sde"},
+	}
+
+	removed, added := diffVdevBlocks(old, new, deviceBlocksMatch)
+
+	if len(removed) != 1 || removed[0].(map[string]interface{})["path"] != "/dev/sda" {
+		t.Fatalf("expected /dev/sda to be removed, got %#v", removed)
+	}
+	if len(added) != 1 || added[0].(map[string]interface{})["path"] != "/dev/sde" {
+		t.Fatalf("expected /dev/sde to be added, got %#v", added)
+	}
+}
+
+// TestRaidzBlocksMatch verifies that raidz vdevs may grow, but not shrink
+// or change parity, and still be considered the same vdev.
+func TestRaidzBlocksMatch(t *testing.T) {
+	old := map[string]interface{}{
+		"parity": 1,
+		"device": []interface{}{
+			map[string]interface{}{"path": "/dev/sda"},
+			map[string]interface{}{"path": "/dev/sdb"},
+		},
+	}
+	expanded := map[string]interface{}{
+		"parity": 1,
+		"device": []interface{}{
+			map[string]interface{}{"path": "/dev/sda"},
+			map[string]interface{}{"path": "/dev/sdb"},
+			map[string]interface{}{"path": "/dev/sdc"},
+		},
+	}
+	reparitied := map[string]interface{}{
+		"parity": 2,
+		"device": expanded["device"],
+	}
+
+	if !raidzBlocksMatch(old, expanded) {
+		t.Fatalf("expected expanded raidz vdev to match")
+	}
+	if raidzBlocksMatch(expanded, old) {
+		t.Fatalf("expected shrunk raidz vdev not to match")
+	}
+	if raidzBlocksMatch(old, reparitied) {
+		t.Fatalf("expected raidz vdev with different parity not to match")
+	}
+}
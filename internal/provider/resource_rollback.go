@@ -0,0 +1,126 @@
+package provider
+
+import (
+	"context"
+	"log"
+	"regexp"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+var snapshotNamePattern = regexp.MustCompile(`^[^@]+@[^@]+$`)
+
+func resourceRollback() *schema.Resource {
+	return &schema.Resource{
+		// This description is used by the documentation generator and the language server.
+		Description: "Rolls a dataset back to a snapshot using `zfs rollback`. Change `triggers` to roll back again.",
+
+		CreateContext: resourceRollbackCreate,
+		ReadContext:   resourceRollbackRead,
+		DeleteContext: resourceRollbackDelete,
+
+		Schema: map[string]*schema.Schema{
+			"snapshot": {
+				Description:      "Name of the snapshot to roll back to, such as `tank/test@golden`.",
+				Type:             schema.TypeString,
+				Required:         true,
+				ForceNew:         true,
+				ValidateDiagFunc: validation.ToDiagFunc(validation.StringMatch(snapshotNamePattern, "must be a snapshot name of the form dataset@snapshot")),
+			},
+			"destroy_newer_snapshots": {
+				Description: "Destroy any snapshots and bookmarks more recent than the one rolled back to (`-r`). Defaults to `false`",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				ForceNew:    true,
+				Default:     false,
+			},
+			"destroy_clones": {
+				Description: "Destroy any more recent snapshots and bookmarks, as well as any clones of them (`-R`). Defaults to `false`",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				ForceNew:    true,
+				Default:     false,
+			},
+			"force_unmount": {
+				Description: "Force an unmount of any clones being destroyed (`-f`). Only used with `destroy_clones`. Defaults to `false`",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				ForceNew:    true,
+				Default:     false,
+			},
+			"triggers": {
+				Description: "Arbitrary map of values which, when changed, will roll the dataset back again.",
+				Type:        schema.TypeMap,
+				Optional:    true,
+				ForceNew:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+			},
+			"dataset": {
+				Description: "Name of the dataset which was rolled back.",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+		},
+	}
+}
+
+func resourceRollbackCreate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	config := meta.(*Config)
+	snapshotName := d.Get("snapshot").(string)
+
+	properties := make(map[string]Property)
+	if err := readSomeProperties(config, "zfs", snapshotName, "guid", properties); err != nil {
+		return diag.FromErr(err)
+	}
+
+	if err := rollbackDataset(config, &Rollback{
+		snapshot:      snapshotName,
+		destroyNewer:  d.Get("destroy_newer_snapshots").(bool),
+		destroyClones: d.Get("destroy_clones").(bool),
+		force:         d.Get("force_unmount").(bool),
+	}); err != nil {
+		return diag.FromErr(err)
+	}
+
+	guid := properties["guid"].value
+	log.Printf("[DEBUG] committing guid: %s", guid)
+	d.SetId(guid)
+
+	return resourceRollbackRead(ctx, d, meta)
+}
+
+func resourceRollbackRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	config := meta.(*Config)
+
+	real_name, err := getSnapshotNameByGuid(config, d.Id())
+	if _, ok := err.(*DatasetError); ok {
+		// Without the snapshot there is nothing to roll back to, so the rollback is recreated to surface the error.
+		log.Printf("[DEBUG] snapshot %s identified by guid %s no longer exists", d.Get("snapshot").(string), d.Id())
+		d.SetId("")
+		return diags
+	} else if err != nil {
+		return diag.FromErr(err)
+	}
+
+	// The configured snapshot name is kept in state, as renaming the snapshot shouldn't roll the dataset back again.
+	dataset, _, _ := strings.Cut(*real_name, "@")
+	if err := d.Set("dataset", dataset); err != nil {
+		return diag.FromErr(err)
+	}
+
+	return diags
+}
+
+func resourceRollbackDelete(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	// A rollback cannot be undone, there is nothing to clean up.
+	d.SetId("")
+
+	return diags
+}
@@ -0,0 +1,43 @@
+package provider
+
+import (
+	"testing"
+)
+
+// TestFindNameByGuid verifies that names are looked up by guid in the
+// output of `zfs list -H -o name,guid`.
+func TestFindNameByGuid(t *testing.T) {
+	stdout := "tank/test@golden\t1234\ntank/test@nightly\t5678\n"
+
+	name, err := findNameByGuid(stdout, "5678")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if *name != "tank/test@nightly" {
+		t.Fatalf("expected tank/test@nightly, got %s", *name)
+	}
+
+	// Only a missing guid is reported as a DatasetError, which lets a rollback tell it apart from connection errors.
+	if _, err := findNameByGuid(stdout, "9999"); err == nil {
+		t.Fatalf("expected an error for an unknown guid")
+	} else if _, ok := err.(*DatasetError); !ok {
+		t.Fatalf("expected a DatasetError for an unknown guid, got %T", err)
+	}
+}
+
+// TestSnapshotNamePattern verifies that only full snapshot names are
+// accepted as rollback targets.
+func TestSnapshotNamePattern(t *testing.T) {
+	for name, valid := range map[string]bool{
+		"tank/test@golden": true,
+		"tank@golden":      true,
+		"tank/test":        false,
+		"@golden":          false,
+		"tank/test@":       false,
+		"tank/test#golden": false,
+	} {
+		if snapshotNamePattern.MatchString(name) != valid {
+			t.Fatalf("expected %s to be valid: %v", name, valid)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+func resourceSnapshotHold() *schema.Resource {
+	return &schema.Resource{
+		// This description is used by the documentation generator and the language server.
+		Description: "Places a hold on a snapshot using `zfs hold`, which prevents it from being destroyed. The hold is released when the resource is destroyed.",
+
+		CreateContext: resourceSnapshotHoldCreate,
+		ReadContext:   resourceSnapshotHoldRead,
+		DeleteContext: resourceSnapshotHoldDelete,
+
+		Importer: &schema.ResourceImporter{
+			StateContext: schema.ImportStatePassthroughContext,
+		},
+
+		Schema: map[string]*schema.Schema{
+			"snapshot": {
+				Description:      "Name of the snapshot to hold, such as `tank/test@golden`.",
+				Type:             schema.TypeString,
+				Required:         true,
+				ForceNew:         true,
+				ValidateDiagFunc: validation.ToDiagFunc(validation.StringMatch(snapshotNamePattern, "must be a snapshot name of the form dataset@snapshot")),
+			},
+			"tag": {
+				Description:      "Tag identifying the hold.",
+				Type:             schema.TypeString,
+				Required:         true,
+				ForceNew:         true,
+				ValidateDiagFunc: validation.ToDiagFunc(validation.StringIsNotWhiteSpace),
+			},
+			"recursive": {
+				Description: "Also hold the snapshots of the same name of all descendant datasets (`-r`). Defaults to `false`",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				ForceNew:    true,
+				Default:     false,
+			},
+			"snapshot_name": {
+				Description: "Current name of the held snapshot, which differs from `snapshot` after the snapshot was renamed.",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+			"timestamp": {
+				Description: "Time at which the hold was placed, as reported by `zfs holds`.",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+		},
+	}
+}
+
+// The snapshot guid comes first in the id, since tags can contain any character.
+func getSnapshotHoldId(guid string, tag string) string {
+	return fmt.Sprintf("%s:%s", guid, tag)
+}
+
+func parseSnapshotHoldId(id string) (string, string, error) {
+	guid, tag, ok := strings.Cut(id, ":")
+	if !ok || guid == "" || tag == "" {
+		return "", "", fmt.Errorf("invalid snapshot hold id %s, expected <snapshot guid>:<tag>", id)
+	}
+	return guid, tag, nil
+}
+
+func resourceSnapshotHoldCreate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	config := meta.(*Config)
+	snapshotName := d.Get("snapshot").(string)
+	tag := d.Get("tag").(string)
+
+	properties := make(map[string]Property)
+	if err := readSomeProperties(config, "zfs", snapshotName, "guid", properties); err != nil {
+		return diag.FromErr(err)
+	}
+
+	if err := holdSnapshot(config, snapshotName, tag, d.Get("recursive").(bool)); err != nil {
+		return diag.FromErr(err)
+	}
+
+	id := getSnapshotHoldId(properties["guid"].value, tag)
+	log.Printf("[DEBUG] committing id: %s", id)
+	d.SetId(id)
+
+	return resourceSnapshotHoldRead(ctx, d, meta)
+}
+
+func resourceSnapshotHoldRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	config := meta.(*Config)
+
+	guid, tag, err := parseSnapshotHoldId(d.Id())
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	real_name, err := getSnapshotNameByGuid(config, guid)
+	if err != nil {
+		return diag.FromErr(fmt.Errorf("the snapshot %s identified by guid %s could not be found. It was likely deleted on the server outside of terraform", d.Get("snapshot").(string), guid))
+	}
+
+	holds, err := getSnapshotHolds(config, *real_name)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	timestamp, ok := holds[tag]
+	if !ok {
+		// The hold was released outside of terraform, so it needs to be placed again.
+		log.Printf("[DEBUG] snapshot %s has no hold %s", *real_name, tag)
+		d.SetId("")
+		return diags
+	}
+
+	if err := updateSnapshotNameInState(d, *real_name); err != nil {
+		return diag.FromErr(err)
+	}
+	if err := d.Set("tag", tag); err != nil {
+		return diag.FromErr(err)
+	}
+	if err := d.Set("timestamp", timestamp); err != nil {
+		return diag.FromErr(err)
+	}
+
+	return diags
+}
+
+// updateSnapshotNameInState keeps the configured snapshot name, as renaming the snapshot shouldn't replace the hold.
+// Only imported holds have no snapshot name yet.
+func updateSnapshotNameInState(d *schema.ResourceData, realName string) error {
+	if d.Get("snapshot").(string) == "" {
+		if err := d.Set("snapshot", realName); err != nil {
+			return err
+		}
+	}
+	return d.Set("snapshot_name", realName)
+}
+
+func resourceSnapshotHoldDelete(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	config := meta.(*Config)
+
+	guid, tag, err := parseSnapshotHoldId(d.Id())
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	real_name, err := getSnapshotNameByGuid(config, guid)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	if err := releaseSnapshot(config, *real_name, tag, d.Get("recursive").(bool)); err != nil {
+		return diag.FromErr(err)
+	}
+
+	d.SetId("")
+
+	return diags
+}
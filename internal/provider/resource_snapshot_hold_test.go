@@ -0,0 +1,69 @@
+package provider
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// TestParseHolds verifies that the tags listed by `zfs holds -H` are
+// mapped to the time the hold was placed.
+func TestParseHolds(t *testing.T) {
+	stdout := "tank/test@golden\treplication\tSun Oct 18 00:24 2026\ntank/test@golden\tkeep me\tSun Oct 18 01:00 2026"
+	expected := map[string]string{
+		"replication": "Sun Oct 18 00:24 2026",
+		"keep me":     "Sun Oct 18 01:00 2026",
+	}
+
+	if holds := parseHolds(stdout); !reflect.DeepEqual(holds, expected) {
+		t.Fatalf("expected %v, got %v", expected, holds)
+	}
+	if holds := parseHolds(""); len(holds) != 0 {
+		t.Fatalf("expected no holds, got %v", holds)
+	}
+}
+
+// TestParseSnapshotHoldId verifies that hold ids are split into the
+// snapshot guid and the tag, which may itself contain colons.
+func TestParseSnapshotHoldId(t *testing.T) {
+	guid, tag, err := parseSnapshotHoldId(getSnapshotHoldId("1234", "backup:daily"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if guid != "1234" || tag != "backup:daily" {
+		t.Fatalf("expected 1234 and backup:daily, got %s and %s", guid, tag)
+	}
+
+	for _, id := range []string{"1234", ":tag", "1234:"} {
+		if _, _, err := parseSnapshotHoldId(id); err == nil {
+			t.Fatalf("expected an error parsing %s", id)
+		}
+	}
+}
+
+// TestUpdateSnapshotNameInState verifies that a renamed snapshot keeps its
+// configured name, while imported holds take the current one.
+func TestUpdateSnapshotNameInState(t *testing.T) {
+	d := schema.TestResourceDataRaw(t, resourceSnapshotHold().Schema, map[string]interface{}{
+		"snapshot": "tank/test@golden",
+		"tag":      "keep",
+	})
+	if err := updateSnapshotNameInState(d, "tank/test@renamed"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if snapshot := d.Get("snapshot").(string); snapshot != "tank/test@golden" {
+		t.Fatalf("expected the configured snapshot tank/test@golden, got %s", snapshot)
+	}
+	if name := d.Get("snapshot_name").(string); name != "tank/test@renamed" {
+		t.Fatalf("expected the snapshot name tank/test@renamed, got %s", name)
+	}
+
+	d = schema.TestResourceDataRaw(t, resourceSnapshotHold().Schema, map[string]interface{}{})
+	if err := updateSnapshotNameInState(d, "tank/test@renamed"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if snapshot := d.Get("snapshot").(string); snapshot != "tank/test@renamed" {
+		t.Fatalf("expected the imported snapshot tank/test@renamed, got %s", snapshot)
+	}
+}
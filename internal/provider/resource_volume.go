@@ -7,6 +7,7 @@ import (
 
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
 )
 
 func resourceVolume() *schema.Resource {
@@ -19,6 +20,8 @@ func resourceVolume() *schema.Resource {
 		UpdateContext: resourceVolumeUpdate,
 		DeleteContext: resourceVolumeDelete,
 
+		CustomizeDiff: resourceVolumeCustomizeDiff,
+
 		Importer: &schema.ResourceImporter{
 			StateContext: schema.ImportStatePassthroughContext,
 		},
@@ -31,10 +34,18 @@ func resourceVolume() *schema.Resource {
 				Required:    true,
 			},
 			"volsize": {
-				Description: "Size of the volume.",
-				Type:        schema.TypeString,
-				Optional:    false,
-				Required:    true,
+				Description:      "Size of the volume, either in bytes or with a suffix such as `10G` or `10GiB`. Shrinking the volume requires `allow_shrink`.",
+				Type:             schema.TypeString,
+				Optional:         false,
+				Required:         true,
+				ValidateDiagFunc: validation.ToDiagFunc(validateSize),
+				DiffSuppressFunc: suppressEquivalentSizeDiff,
+			},
+			"allow_shrink": {
+				Description: "Allow `volsize` to be decreased. Shrinking a volume discards any data past the new size. Defaults to `false`",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
 			},
 			"sparse": {
 				Description: "If the volume is sparsely provisioned. Defaults to `false`",
@@ -42,10 +53,20 @@ func resourceVolume() *schema.Resource {
 				Optional:    true,
 				Default:     false,
 			},
-			"property":       &propertySchema,
-			"property_mode":  &propertyModeSchema,
-			"properties":     &propertiesSchema,
-			"raw_properties": &rawPropertiesSchema,
+			"compression":          datasetAttributeSchema("compression", TargetVolume),
+			"dedup":                datasetAttributeSchema("dedup", TargetVolume),
+			"readonly":             datasetAttributeSchema("readonly", TargetVolume),
+			"refreservation":       datasetAttributeSchema("refreservation", TargetVolume),
+			"reservation":          datasetAttributeSchema("reservation", TargetVolume),
+			"sync":                 datasetAttributeSchema("sync", TargetVolume),
+			"property":             &propertySchema,
+			"property_mode":        &propertyModeSchema,
+			"user_properties":      &userPropertiesSchema,
+			"user_properties_mode": &userPropertiesModeSchema,
+			"properties":           &propertiesSchema,
+			"raw_properties":       &rawPropertiesSchema,
+			"property_sources":     &propertySourcesSchema,
+			"inherited_from":       &inheritedFromSchema,
 		},
 	}
 }
@@ -82,6 +103,12 @@ func resourceVolumeCreate(ctx context.Context, d *schema.ResourceData, meta inte
 	volsize := d.Get("volsize").(string)
 	sparse := d.Get("sparse").(bool)
 	properties := parsePropertyBlocks(d.Get("property").(*schema.Set).List())
+	for name, value := range mergeProperties(getDatasetAttributeProperties(d, TargetVolume), getUserProperties(d)) {
+		if _, ok := properties[name]; ok {
+			return diag.FromErr(fmt.Errorf("don't set '%s' as a property block, use the dedicated attribute instead", name))
+		}
+		properties[name] = value
+	}
 	volume, err = createDataset(config, &CreateDataset{
 		dsType:     VolumeType,
 		name:       volumeName,
@@ -131,7 +158,20 @@ func resourceVolumeRead(ctx context.Context, d *schema.ResourceData, meta interf
 		return diag.FromErr(err)
 	}
 
-	if err := updatePropertiesInState(d, volume.properties, []string{"volsize"}); err != nil {
+	if err := updateDatasetAttributesInState(d, volume.properties, TargetVolume); err != nil {
+		return diag.FromErr(err)
+	}
+
+	// Properties managed through dedicated attributes are kept out of the property blocks.
+	ignoredProperties := []string{"volsize"}
+	ignoredProperties = append(ignoredProperties, getIgnoredDatasetAttributes(d, TargetVolume)...)
+	ignoredProperties = append(ignoredProperties, getIgnoredUserProperties(d, volume.properties)...)
+
+	if err := updateUserPropertiesInState(d, volume.properties); err != nil {
+		return diag.FromErr(err)
+	}
+
+	if err := updatePropertiesInState(d, volume.properties, ignoredProperties); err != nil {
 		return diag.FromErr(err)
 	}
 
@@ -160,6 +200,14 @@ func resourceVolumeUpdate(ctx context.Context, d *schema.ResourceData, meta inte
 	}
 
 	overrideProperties := map[string]string{"volsize": d.Get("volsize").(string)}
+	for name, value := range mergeProperties(getDatasetAttributeProperties(d, TargetVolume), getUserProperties(d)) {
+		overrideProperties[name] = value
+	}
+
+	if err := removeUserProperties(config, d, volumeName); err != nil {
+		return diag.FromErr(err)
+	}
+
 	err = applyPropertyDiff(config, d, volumeName, volume.properties, overrideProperties)
 	if err != nil {
 		return diag.FromErr(err)
@@ -181,3 +229,32 @@ func resourceVolumeDelete(ctx context.Context, d *schema.ResourceData, meta inte
 
 	return diags
 }
+
+// resourceVolumeCustomizeDiff validates the properties, and refuses to shrink volumes unless explicitly allowed, since
+// anything written past the new size (such as the tail end of a VM disk) is lost.
+func resourceVolumeCustomizeDiff(ctx context.Context, d *schema.ResourceDiff, meta interface{}) error {
+	if err := customizePropertyDiff(d, TargetVolume); err != nil {
+		return err
+	}
+
+	if d.Id() == "" || !d.HasChange("volsize") || d.Get("allow_shrink").(bool) {
+		return nil
+	}
+
+	old, new := d.GetChange("volsize")
+	oldSize, err := parseSize(old.(string))
+	if err != nil {
+		return err
+	}
+	newSize, err := parseSize(new.(string))
+	if err != nil {
+		// The new size may not be known until apply.
+		return nil
+	}
+
+	if newSize < oldSize {
+		return fmt.Errorf("refusing to shrink volume %s from %d to %d bytes, set allow_shrink = true to allow it", d.Get("name").(string), oldSize, newSize)
+	}
+
+	return nil
+}
@@ -0,0 +1,101 @@
+package provider
+
+import (
+	"testing"
+)
+
+// TestParseSize verifies that raw byte counts as well as suffixed sizes
+// are parsed using the binary units zfs uses.
+func TestParseSize(t *testing.T) {
+	cases := map[string]uint64{
+		"10737418240": 10737418240,
+		"10G":         10737418240,
+		"10g":         10737418240,
+		"10GiB":       10737418240,
+		"10GB":        10737418240,
+		"512K":        524288,
+		"1.5T":        1649267441664,
+		"100B":        100,
+	}
+
+	for value, expected := range cases {
+		size, err := parseSize(value)
+		if err != nil {
+			t.Fatalf("unexpected error parsing %q: %v", value, err)
+		}
+		if size != expected {
+			t.Fatalf("parsing %q: expected %d, got %d", value, expected, size)
+		}
+	}
+
+	for _, value := range []string{"", "G", "ten gigabytes", "10X", "-10G"} {
+		if _, err := parseSize(value); err == nil {
+			t.Fatalf("expected an error parsing %q", value)
+		}
+	}
+}
+
+// TestSuppressEquivalentSizeDiff verifies that the raw byte count read back
+// from zfs does not show up as a diff against the configured size.
+func TestSuppressEquivalentSizeDiff(t *testing.T) {
+	if !suppressEquivalentSizeDiff("volsize", "10737418240", "10G", nil) {
+		t.Fatalf("expected 10737418240 and 10G to be equivalent")
+	}
+	if suppressEquivalentSizeDiff("volsize", "10737418240", "20G", nil) {
+		t.Fatalf("expected 10737418240 and 20G to differ")
+	}
+	if suppressEquivalentSizeDiff("volsize", "", "10G", nil) {
+		t.Fatalf("expected a diff when there is no previous size")
+	}
+}
+
+// TestPropertyValueMatches verifies that size properties are compared by
+// value, while other properties are still compared as strings.
+func TestPropertyValueMatches(t *testing.T) {
+	quota := Property{source: SourceLocal, value: "1G", rawValue: "1073741824"}
+	for _, desired := range []string{"1G", "1024M", "1073741824", "1GiB"} {
+		if !propertyValueMatches("quota", desired, quota) {
+			t.Fatalf("expected quota %q to match %v", desired, quota)
+		}
+	}
+	if propertyValueMatches("quota", "2G", quota) {
+		t.Fatalf("expected quota 2G not to match %v", quota)
+	}
+
+	unset := Property{source: SourceDefault, value: "none", rawValue: "0"}
+	if !propertyValueMatches("refquota", "none", unset) {
+		t.Fatalf("expected refquota none to match %v", unset)
+	}
+
+	if !propertyValueMatches("userquota@alice", "512M", Property{value: "512M", rawValue: "536870912"}) {
+		t.Fatalf("expected userquota@alice to be compared as a size")
+	}
+
+	compression := Property{source: SourceLocal, value: "lz4", rawValue: "lz4"}
+	if propertyValueMatches("compression", "LZ4", compression) {
+		t.Fatalf("expected compression to be compared as a string")
+	}
+}
+
+// TestRefreservationAuto verifies that the reservation zfs computes for
+// refreservation = "auto" is neither planned nor applied as a change.
+func TestRefreservationAuto(t *testing.T) {
+	computed := Property{source: SourceLocal, value: "10.3G", rawValue: "11059527680"}
+	if !propertyValueMatches("refreservation", "auto", computed) {
+		t.Fatalf("expected refreservation auto to match %v", computed)
+	}
+	if !suppressAutoRefreservationDiff("refreservation", "10.3G", "auto", nil) {
+		t.Fatalf("expected no diff between 10.3G and auto")
+	}
+
+	unset := Property{source: SourceDefault, value: "none", rawValue: "0"}
+	if propertyValueMatches("refreservation", "auto", unset) {
+		t.Fatalf("expected refreservation auto not to match %v", unset)
+	}
+	if suppressAutoRefreservationDiff("refreservation", "none", "auto", nil) {
+		t.Fatalf("expected a diff between none and auto")
+	}
+	if suppressAutoRefreservationDiff("refreservation", "10.3G", "5G", nil) {
+		t.Fatalf("expected a diff between 10.3G and 5G")
+	}
+}
@@ -0,0 +1,388 @@
+package provider
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+var nfsShareSchema = schema.Schema{
+	Description: "Share the filesystem over NFS by setting the `sharenfs` property.",
+	Type:        schema.TypeList,
+	Optional:    true,
+	MaxItems:    1,
+	Elem: &schema.Resource{
+		Schema: map[string]*schema.Schema{
+			"client": {
+				Description: "Clients to export the filesystem to. The filesystem is exported to everyone if none are given.",
+				Type:        schema.TypeList,
+				Optional:    true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"hosts": {
+							Description: "Hosts, networks (such as `@10.0.0.0/24`) or netgroups to export to.",
+							Type:        schema.TypeList,
+							Required:    true,
+							MinItems:    1,
+							Elem:        &schema.Schema{Type: schema.TypeString},
+						},
+						"access": {
+							Description:      "Access granted to the hosts. One of `rw` or `ro`. Defaults to `rw`",
+							Type:             schema.TypeString,
+							Optional:         true,
+							Default:          "rw",
+							ValidateDiagFunc: validation.ToDiagFunc(validation.StringInSlice([]string{"rw", "ro"}, false)),
+						},
+					},
+				},
+			},
+			"root_squash": {
+				Description: "Map requests from root to the anonymous user. Defaults to `true`",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     true,
+			},
+			"sec": {
+				Description: "Security flavors to allow, such as `sys` or `krb5p`.",
+				Type:        schema.TypeList,
+				Optional:    true,
+				Elem: &schema.Schema{
+					Type:             schema.TypeString,
+					ValidateDiagFunc: validation.ToDiagFunc(validation.StringInSlice([]string{"sys", "krb5", "krb5i", "krb5p", "none"}, false)),
+				},
+			},
+			"options": {
+				Description: "Any other export options, such as `async` or `crossmnt`.",
+				Type:        schema.TypeList,
+				Optional:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+			},
+		},
+	},
+}
+
+// On Linux, zfs ignores the sharesmb options and derives the share name from the filesystem name, so the block has no
+// options of its own.
+var smbShareSchema = schema.Schema{
+	Description: "Share the filesystem over SMB by setting `sharesmb = on`, as an empty `smb_share {}` block. The share is named after the filesystem, with slashes replaced by underscores.",
+	Type:        schema.TypeList,
+	Optional:    true,
+	MaxItems:    1,
+	Elem: &schema.Resource{
+		Schema: map[string]*schema.Schema{},
+	},
+}
+
+var nfsExportsSchema = schema.Schema{
+	Description: "Active NFS exports of the mountpoint, as reported by `exportfs -v`.",
+	Type:        schema.TypeList,
+	Computed:    true,
+	Elem: &schema.Resource{
+		Schema: map[string]*schema.Schema{
+			"client": {
+				Description: "Client the mountpoint is exported to.",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+			"options": {
+				Description: "Options the mountpoint is exported with.",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+		},
+	},
+}
+
+var smbUsersharesSchema = schema.Schema{
+	Description: "Active SMB shares of the mountpoint, as reported by `net usershare info`.",
+	Type:        schema.TypeList,
+	Computed:    true,
+	Elem: &schema.Resource{
+		Schema: map[string]*schema.Schema{
+			"name": {
+				Description: "Name of the share.",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+			"acl": {
+				Description: "Access control list of the share, such as `Everyone:F,`.",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+			"guest_ok": {
+				Description: "Whether guests can access the share.",
+				Type:        schema.TypeBool,
+				Computed:    true,
+			},
+		},
+	},
+}
+
+func stringList(values interface{}) []string {
+	out := make([]string, 0)
+	for _, value := range values.([]interface{}) {
+		out = append(out, value.(string))
+	}
+	return out
+}
+
+func interfaceList(values []string) []interface{} {
+	out := make([]interface{}, 0)
+	for _, value := range values {
+		out = append(out, value)
+	}
+	return out
+}
+
+// renderNfsShare renders an nfs_share block as a sharenfs property, such as rw=@10.0.0.0/24,no_root_squash,sec=krb5p.
+func renderNfsShare(share map[string]interface{}) string {
+	options := make([]string, 0)
+	for _, client := range share["client"].([]interface{}) {
+		client := client.(map[string]interface{})
+		options = append(options, fmt.Sprintf("%s=%s", client["access"].(string), strings.Join(stringList(client["hosts"]), ":")))
+	}
+	if !share["root_squash"].(bool) {
+		options = append(options, "no_root_squash")
+	}
+	if sec := stringList(share["sec"]); len(sec) > 0 {
+		options = append(options, "sec="+strings.Join(sec, ":"))
+	}
+	options = append(options, stringList(share["options"])...)
+
+	if len(options) == 0 {
+		return "on"
+	}
+	return strings.Join(options, ",")
+}
+
+// parseNfsShare is the inverse of renderNfsShare, used to detect changes made to the sharenfs property.
+func parseNfsShare(value string) map[string]interface{} {
+	share := map[string]interface{}{
+		"client":      []interface{}{},
+		"root_squash": true,
+		"sec":         []interface{}{},
+		"options":     []interface{}{},
+	}
+	if value == "on" {
+		return share
+	}
+
+	clients := make([]interface{}, 0)
+	options := make([]interface{}, 0)
+	for _, option := range strings.Split(value, ",") {
+		name, argument, _ := strings.Cut(option, "=")
+		switch name {
+		case "rw", "ro":
+			clients = append(clients, map[string]interface{}{
+				"access": name,
+				"hosts":  interfaceList(strings.Split(argument, ":")),
+			})
+		case "no_root_squash":
+			share["root_squash"] = false
+		case "sec":
+			share["sec"] = interfaceList(strings.Split(argument, ":"))
+		default:
+			options = append(options, option)
+		}
+	}
+	share["client"] = clients
+	share["options"] = options
+	return share
+}
+
+func renderSmbShare(share map[string]interface{}) string {
+	return "on"
+}
+
+func parseSmbShare(value string) map[string]interface{} {
+	return map[string]interface{}{}
+}
+
+var shareBlocks = []struct {
+	key      string
+	property string
+	render   func(map[string]interface{}) string
+}{{"nfs_share", "sharenfs", renderNfsShare}, {"smb_share", "sharesmb", renderSmbShare}}
+
+// getShareProperties returns the sharenfs and sharesmb properties rendered from the share blocks. The empty smb_share
+// block is read back as nil.
+func getShareProperties(d *schema.ResourceData) map[string]string {
+	properties := make(map[string]string)
+	for _, share := range shareBlocks {
+		if blocks := d.Get(share.key).([]interface{}); len(blocks) > 0 {
+			block, _ := blocks[0].(map[string]interface{})
+			properties[share.property] = share.render(block)
+		}
+	}
+	return properties
+}
+
+// removeShareProperties inherits the share properties of the share blocks which were removed.
+func removeShareProperties(config *Config, d *schema.ResourceData, datasetName string) error {
+	for _, share := range shareBlocks {
+		if old, new := d.GetChange(share.key); len(old.([]interface{})) > 0 && len(new.([]interface{})) == 0 {
+			if _, err := callSshCommand(config, "zfs inherit %s %s", share.property, datasetName); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func updateSharesInState(d *schema.ResourceData, properties map[string]Property) error {
+	if blocks := d.Get("nfs_share").([]interface{}); len(blocks) > 0 {
+		share := []interface{}{}
+		if value := properties["sharenfs"].value; value != "off" {
+			share = append(share, parseNfsShare(value))
+		}
+		if err := d.Set("nfs_share", share); err != nil {
+			return err
+		}
+	}
+
+	if blocks := d.Get("smb_share").([]interface{}); len(blocks) > 0 {
+		share := []interface{}{}
+		if value := properties["sharesmb"].value; value != "off" {
+			share = append(share, parseSmbShare(value))
+		}
+		if err := d.Set("smb_share", share); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// getIgnoredShareProperties returns the share properties managed through the share blocks.
+func getIgnoredShareProperties(d *schema.ResourceData) []string {
+	ignored := make([]string, 0)
+	if len(d.Get("nfs_share").([]interface{})) > 0 {
+		ignored = append(ignored, "sharenfs")
+	}
+	if len(d.Get("smb_share").([]interface{})) > 0 {
+		ignored = append(ignored, "sharesmb")
+	}
+	return ignored
+}
+
+type NfsExport struct {
+	client  string
+	options string
+}
+
+// parseExportfs parses the output of `exportfs -v`, which puts the clients on the next line if the path is long:
+//
+//	/srv/data     	@10.0.0.0/24(sync,wdelay,hide,no_subtree_check,sec=sys,rw,secure,root_squash,no_all_squash)
+//	/srv/a/very/long/path
+//			<world>(sync,wdelay,hide,no_subtree_check,sec=sys,ro,secure,root_squash,no_all_squash)
+func parseExportfs(stdout string) map[string][]NfsExport {
+	exports := make(map[string][]NfsExport)
+	path := ""
+	for _, field := range strings.Fields(stdout) {
+		if strings.HasPrefix(field, "/") {
+			path = field
+			continue
+		}
+		client, options, _ := strings.Cut(strings.TrimSuffix(field, ")"), "(")
+		exports[path] = append(exports[path], NfsExport{client: client, options: options})
+	}
+	return exports
+}
+
+func getNfsExports(config *Config, path string) ([]NfsExport, error) {
+	stdout, err := callSshCommand(config, "exportfs -v")
+	if err != nil {
+		return nil, err
+	}
+	return parseExportfs(stdout)[path], nil
+}
+
+func flattenNfsExports(exports []NfsExport) []interface{} {
+	out := make([]interface{}, 0)
+	for _, export := range exports {
+		out = append(out, map[string]interface{}{
+			"client":  export.client,
+			"options": export.options,
+		})
+	}
+	return out
+}
+
+type SmbUsershare struct {
+	name    string
+	acl     string
+	guestOk bool
+}
+
+// parseUsershareInfo parses the output of `net usershare info`, grouping the shares by path:
+//
+//	[tank_data]
+//	path=/tank/data
+//	comment=
+//	usershare_acl=Everyone:F,
+//	guest_ok=n
+func parseUsershareInfo(stdout string) map[string][]SmbUsershare {
+	shares := make(map[string][]SmbUsershare)
+	var share *SmbUsershare
+	path := ""
+	flush := func() {
+		if share != nil && path != "" {
+			shares[path] = append(shares[path], *share)
+		}
+	}
+	for _, line := range strings.Split(stdout, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			flush()
+			share = &SmbUsershare{name: strings.Trim(line, "[]")}
+			path = ""
+			continue
+		}
+		key, value, _ := strings.Cut(line, "=")
+		if share == nil {
+			continue
+		}
+		switch key {
+		case "path":
+			path = value
+		case "usershare_acl":
+			share.acl = value
+		case "guest_ok":
+			share.guestOk = value == "y"
+		}
+	}
+	flush()
+	return shares
+}
+
+func getSmbUsershares(config *Config, path string) ([]SmbUsershare, error) {
+	stdout, err := callSshCommand(config, "net usershare info")
+	if err != nil {
+		return nil, err
+	}
+	return parseUsershareInfo(stdout)[path], nil
+}
+
+func flattenSmbUsershares(shares []SmbUsershare) []interface{} {
+	out := make([]interface{}, 0)
+	for _, share := range shares {
+		out = append(out, map[string]interface{}{
+			"name":     share.name,
+			"acl":      share.acl,
+			"guest_ok": share.guestOk,
+		})
+	}
+	return out
+}
+
+// shareFilesystem shares the filesystem, which picks up any changes to its share properties. Setting the properties
+// on a mounted filesystem usually shares it already, which zfs share complains about.
+func shareFilesystem(config *Config, filesystemName string) error {
+	_, err := callSshCommand(config, "zfs share %s", filesystemName)
+	if err, ok := err.(*StderrError); ok && strings.Contains(err.stderr, "already shared") {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,96 @@
+package provider
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// TestRenderNfsShare verifies that nfs_share blocks are rendered as
+// sharenfs properties, and parsed back into the same block.
+func TestRenderNfsShare(t *testing.T) {
+	cases := map[string]map[string]interface{}{
+		"on": {
+			"client":      []interface{}{},
+			"root_squash": true,
+			"sec":         []interface{}{},
+			"options":     []interface{}{},
+		},
+		"rw=@10.0.0.0/24:@10.0.1.0/24,ro=backup.example.com,no_root_squash,sec=krb5:krb5p,async": {
+			"client": []interface{}{
+				map[string]interface{}{"access": "rw", "hosts": []interface{}{"@10.0.0.0/24", "@10.0.1.0/24"}},
+				map[string]interface{}{"access": "ro", "hosts": []interface{}{"backup.example.com"}},
+			},
+			"root_squash": false,
+			"sec":         []interface{}{"krb5", "krb5p"},
+			"options":     []interface{}{"async"},
+		},
+	}
+
+	for expected, share := range cases {
+		if rendered := renderNfsShare(share); rendered != expected {
+			t.Fatalf("expected %s, got %s", expected, rendered)
+		}
+		if parsed := parseNfsShare(expected); !reflect.DeepEqual(parsed, share) {
+			t.Fatalf("parsing %s: expected %v, got %v", expected, share, parsed)
+		}
+	}
+}
+
+// TestGetShareProperties verifies that the share blocks are rendered as
+// share properties, including the empty smb_share block.
+func TestGetShareProperties(t *testing.T) {
+	d := schema.TestResourceDataRaw(t, resourceFilesystem().Schema, map[string]interface{}{
+		"name":      "tank/media",
+		"nfs_share": []interface{}{map[string]interface{}{}},
+		"smb_share": []interface{}{map[string]interface{}{}},
+	})
+
+	expected := map[string]string{"sharenfs": "on", "sharesmb": "on"}
+	if properties := getShareProperties(d); !reflect.DeepEqual(properties, expected) {
+		t.Fatalf("expected %v, got %v", expected, properties)
+	}
+
+	d = schema.TestResourceDataRaw(t, resourceFilesystem().Schema, map[string]interface{}{"name": "tank/media"})
+	if properties := getShareProperties(d); len(properties) != 0 {
+		t.Fatalf("expected no share properties, got %v", properties)
+	}
+}
+
+// TestParseUsershareInfo verifies that the smb shares listed by net
+// usershare are grouped by path.
+func TestParseUsershareInfo(t *testing.T) {
+	stdout := "[tank_media]\npath=/tank/media\ncomment=\nusershare_acl=Everyone:F,\nguest_ok=n\n\n" +
+		"[tank_public]\npath=/tank/public\ncomment=\nusershare_acl=Everyone:R,\nguest_ok=y\n"
+
+	shares := parseUsershareInfo(stdout)
+	if !reflect.DeepEqual(shares["/tank/media"], []SmbUsershare{{name: "tank_media", acl: "Everyone:F,"}}) {
+		t.Fatalf("unexpected shares of /tank/media: %v", shares["/tank/media"])
+	}
+	if !reflect.DeepEqual(shares["/tank/public"], []SmbUsershare{{name: "tank_public", acl: "Everyone:R,", guestOk: true}}) {
+		t.Fatalf("unexpected shares of /tank/public: %v", shares["/tank/public"])
+	}
+	if len(parseUsershareInfo("")) != 0 {
+		t.Fatalf("expected no shares")
+	}
+}
+
+// TestParseExportfs verifies that exports are grouped by path, including
+// paths long enough for exportfs to put the clients on the next line.
+func TestParseExportfs(t *testing.T) {
+	stdout := "/srv/data     \t@10.0.0.0/24(sync,wdelay,hide,no_subtree_check,sec=sys,rw,secure,root_squash,no_all_squash)\n" +
+		"/srv/data     \tbackup.example.com(sync,wdelay,hide,no_subtree_check,sec=sys,ro,secure,root_squash,no_all_squash)\n" +
+		"/srv/a/very/long/path/to/a/filesystem\n" +
+		"\t\t<world>(sync,wdelay,hide,no_subtree_check,sec=sys,ro,secure,root_squash,no_all_squash)\n"
+
+	exports := parseExportfs(stdout)
+	if len(exports["/srv/data"]) != 2 || exports["/srv/data"][1].client != "backup.example.com" {
+		t.Fatalf("unexpected exports of /srv/data: %v", exports["/srv/data"])
+	}
+
+	long := exports["/srv/a/very/long/path/to/a/filesystem"]
+	if len(long) != 1 || long[0].client != "<world>" || long[0].options != "sync,wdelay,hide,no_subtree_check,sec=sys,ro,secure,root_squash,no_all_squash" {
+		t.Fatalf("unexpected exports of the long path: %v", long)
+	}
+}
@@ -1,11 +1,15 @@
 package provider
 
 import (
+	"context"
 	"encoding/csv"
 	"fmt"
 	"io"
 	"log"
+	"regexp"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/alessio/shellescape"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
@@ -49,42 +53,9 @@ func parsePropertySource(input string) (PropertySource, error) {
 	}
 }
 
-var poolProperties = []string{
-	"allocated",
-	"altroot",
-	"ashift",
-	"autoexpand",
-	"autoreplace",
-	"autotrim",
-	"bootfs",
-	"cachefile",
-	"capacity",
-	"checkpoint",
-	"comment",
-	"compatibility",
-	"dedupratio",
-	"delegation",
-	"expandsize",
-	"failmode",
-	"fragmentation",
-	"free",
-	"freeing",
-	"guid",
-	"health",
-	"leaked",
-	"listsnapshots",
-	"load_guid",
-	"multihost",
-	"readonly",
-	"size",
-	"version",
-}
-
 func isPoolProperty(property string) bool {
-	for _, poolProperty := range poolProperties {
-		if property == poolProperty {
-			return true
-		}
+	if _, ok := poolPropertyCatalog[property]; ok {
+		return true
 	}
 	return strings.HasPrefix(property, "feature@")
 }
@@ -93,6 +64,56 @@ type Property struct {
 	source   PropertySource
 	value    string
 	rawValue string
+	// Name of the dataset the property is inherited from, if the source is inherited.
+	inheritedFrom string
+}
+
+var sizeProperties = []string{
+	"quota",
+	"recordsize",
+	"refquota",
+	"refreservation",
+	"reservation",
+	"special_small_blocks",
+	"volblocksize",
+	"volsize",
+}
+
+func isSizeProperty(property string) bool {
+	for _, sizeProperty := range sizeProperties {
+		if property == sizeProperty {
+			return true
+		}
+	}
+	return strings.Contains(property, "quota@")
+}
+
+// propertyValueMatches compares a desired property value against the actual one, treating sizes such as 1G, 1024M and
+// the raw byte count as equal.
+func propertyValueMatches(name string, desired string, actual Property) bool {
+	if desired == actual.value || desired == actual.rawValue {
+		return true
+	}
+	if !isSizeProperty(name) {
+		return false
+	}
+
+	if name == "refreservation" && desired == "auto" {
+		return isAutoRefreservation(actual.rawValue)
+	}
+	// Unset quotas and reservations are reported as "none", but as 0 in parseable mode.
+	if desired == "none" {
+		desired = "0"
+	}
+	desiredSize, err := parseSize(desired)
+	if err != nil {
+		return false
+	}
+	actualSize, err := parseSize(actual.rawValue)
+	if err != nil {
+		return false
+	}
+	return desiredSize == actualSize
 }
 
 func readSomeProperties(config *Config, baseCommand string, resourceName string, propertyName string, properties map[string]Property) error {
@@ -117,6 +138,9 @@ func readSomeProperties(config *Config, baseCommand string, resourceName string,
 		property.value = line[2]
 		if source, err := parsePropertySource(line[1]); err == nil {
 			property.source = source
+			if source == SourceInherited {
+				property.inheritedFrom = strings.TrimPrefix(line[1], "inherited from ")
+			}
 		} else {
 			return fmt.Errorf("Error in property %s: %s", name, err)
 		}
@@ -195,6 +219,12 @@ func updateCalculatedPropertiesInState(d *schema.ResourceData, properties map[st
 	if err := d.Set("properties", flattenProperties(properties)); err != nil {
 		return err
 	}
+	if err := d.Set("property_sources", flattenPropertySources(properties)); err != nil {
+		return err
+	}
+	if err := d.Set("inherited_from", flattenInheritedFrom(properties)); err != nil {
+		return err
+	}
 	return d.Set("raw_properties", flattenRawProperties(properties))
 }
 
@@ -255,8 +285,8 @@ func updatePropertiesInState(d *schema.ResourceData, properties map[string]Prope
 		block := make(map[string]interface{}, 0)
 		block["name"] = name
 		block["value"] = property.value
-		if defined[name] == property.rawValue {
-			block["value"] = property.rawValue
+		if value, ok := defined[name]; ok && propertyValueMatches(name, value, property) {
+			block["value"] = value
 		}
 		blocks = append(blocks, block)
 	}
@@ -267,8 +297,11 @@ func getResetCommand(property string) (string, bool) {
 	if isPoolProperty(property) {
 		return "zpool properties cannot be reset back to a default value", false
 	}
-	if strings.Contains(property, "quota@") {
-		return fmt.Sprintf("zfs set %s=none", shellescape.Quote(property)), true
+	if definition, ok := lookupProperty(property, TargetDataset); ok && !definition.inheritable {
+		if definition.resetValue == "" {
+			return fmt.Sprintf("%s cannot be reset back to a default value", property), false
+		}
+		return fmt.Sprintf("zfs set %s=%s", shellescape.Quote(property), definition.resetValue), true
 	}
 	return fmt.Sprintf("zfs inherit -S %s", shellescape.Quote(property)), true
 }
@@ -315,9 +348,10 @@ func applyPropertyDiff(
 	log.Printf("[DEBUG] desired properties: %s", desiredProperties)
 	log.Printf("[DEBUG] actual properties: %s", actualProperties)
 	for name, value := range desiredProperties {
-		if value != actualProperties[name].value {
+		if !propertyValueMatches(name, value, actualProperties[name]) {
 			baseCommand := "zfs"
-			if isPoolProperty(name) {
+			// Some properties such as readonly exist on both pools and datasets, but only the root dataset is a pool.
+			if isPoolProperty(name) && !strings.Contains(targetName, "/") {
 				baseCommand = "zpool"
 			}
 			if _, err := callSshCommand(config, "%s set %s=%s %s", baseCommand, shellescape.Quote(name), shellescape.Quote(value), targetName); err != nil {
@@ -349,6 +383,11 @@ func getZfsResourceNameByGuid(config *Config, resource_type string, guid string)
 		return nil, err
 	}
 
+	return findNameByGuid(stdout, guid)
+}
+
+// findNameByGuid finds the name belonging to the guid in the tab-separated name,guid output of `zfs list`.
+func findNameByGuid(stdout string, guid string) (*string, error) {
 	reader := csv.NewReader(strings.NewReader(stdout))
 	reader.Comma = '\t'
 
@@ -365,7 +404,7 @@ func getZfsResourceNameByGuid(config *Config, resource_type string, guid string)
 		}
 	}
 
-	return nil, fmt.Errorf("no resource found with guid %s", guid)
+	return nil, &DatasetError{errmsg: fmt.Sprintf("no resource found with guid %s", guid)}
 }
 
 func getDatasetNameByGuid(config *Config, guid string) (*string, error) {
@@ -376,6 +415,43 @@ func getPoolNameByGuid(config *Config, guid string) (*string, error) {
 	return getZfsResourceNameByGuid(config, "zpool", guid)
 }
 
+// listZfsGuidsOfType lists snapshots or bookmarks, which `zfs list` leaves out unless asked for by type.
+func listZfsGuidsOfType(config *Config, dataset_type string) (string, error) {
+	stdout, err := callSshCommand(config, "zfs list -H -t %s -o name,guid", dataset_type)
+	if err != nil && strings.Contains(err.Error(), "no datasets available") {
+		return "", nil
+	}
+	return stdout, err
+}
+
+func getSnapshotNameByGuid(config *Config, guid string) (*string, error) {
+	stdout, err := listZfsGuidsOfType(config, "snapshot")
+	if err != nil {
+		return nil, err
+	}
+	return findNameByGuid(stdout, guid)
+}
+
+// findNamesByGuid is like findNameByGuid, but returns every name with the guid. Bookmarks share the guid of the
+// snapshot they were created from, so a snapshot bookmarked twice has two bookmarks with the same guid.
+func findNamesByGuid(stdout string, guid string) []string {
+	names := make([]string, 0)
+	for _, line := range strings.Split(stdout, "\n") {
+		if name, lineGuid, ok := strings.Cut(line, "\t"); ok && lineGuid == guid {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func getBookmarkNamesByGuid(config *Config, guid string) ([]string, error) {
+	stdout, err := listZfsGuidsOfType(config, "bookmark")
+	if err != nil {
+		return nil, err
+	}
+	return findNamesByGuid(stdout, guid), nil
+}
+
 func describeDataset(config *Config, datasetName string, requiredProperties []string) (*Dataset, error) {
 	properties := make(map[string]Property, 0)
 	if err := readDatasetProperties(config, datasetName, requiredProperties, properties); err != nil {
@@ -406,10 +482,18 @@ func describeDataset(config *Config, datasetName string, requiredProperties []st
 }
 
 type Device struct {
-	path string
+	path  string
+	state string
 }
 
 type Mirror struct {
+	name    string
+	devices []Device
+}
+
+type Raidz struct {
+	name    string
+	parity  int
 	devices []Device
 }
 
@@ -417,13 +501,28 @@ type Pool struct {
 	guid       string
 	properties map[string]Property
 	layout     PoolLayout
+	// compatibleFeatures are the features allowed by the compatibility property, or nil if all features are.
+	compatibleFeatures map[string]bool
+}
+
+func (pool *Pool) isFeatureCompatible(feature string) bool {
+	return pool.compatibleFeatures == nil || pool.compatibleFeatures[feature]
 }
 
 type PoolLayout struct {
 	mirrors []Mirror
+	raidz   []Raidz
 	striped []Device
+	logs    []Device
+	cache   []Device
+	spares  []Device
 }
 
+var raidzVdevPattern = regexp.MustCompile(`^raidz(\d)-\d+$`)
+
+// interiorVdevPattern matches the vdevs grouping a device with its replacement or the hot spare standing in for it.
+var interiorVdevPattern = regexp.MustCompile(`^(?:replacing|spare)-\d+$`)
+
 func readPoolLayout(config *Config, poolName string) (*PoolLayout, error) {
 	log.Printf("[DEBUG] reading zpool layout for %s", poolName)
 	stdout, err := callSshCommand(config, "zpool list -HPv %s", poolName)
@@ -432,8 +531,14 @@ func readPoolLayout(config *Config, poolName string) (*PoolLayout, error) {
 		return nil, err
 	}
 
+	return parsePoolLayout(stdout)
+}
+
+func parsePoolLayout(stdout string) (*PoolLayout, error) {
 	reader := csv.NewReader(strings.NewReader(stdout))
 	reader.Comma = '\t'
+	// Class headers don't necessarily have the same number of columns as the vdevs.
+	reader.FieldsPerRecord = -1
 
 	// First line of zpool list output is the pool name/statistics themselves,
 	// so we skip this line, of course making sure that the read itself works.
@@ -448,9 +553,20 @@ func readPoolLayout(config *Config, poolName string) (*PoolLayout, error) {
 
 	layout := PoolLayout{
 		mirrors: make([]Mirror, 0),
+		raidz:   make([]Raidz, 0),
 		striped: make([]Device, 0),
+		logs:    make([]Device, 0),
+		cache:   make([]Device, 0),
+		spares:  make([]Device, 0),
 	}
 
+	// Devices belong to the last mirror or raidz vdev defined before them, if any.
+	var group *[]Device
+	// Once an allocation class header has been seen, all following devices belong to that class.
+	var class *[]Device
+	// Number of devices left in the replacing or spare vdev being read.
+	interiorDevices := 0
+
 	for {
 		line, err := reader.Read()
 		if err == io.EOF {
@@ -459,30 +575,94 @@ func readPoolLayout(config *Config, poolName string) (*PoolLayout, error) {
 			return nil, err
 		}
 
+		// Class headers are either printed in the name column of the pool or of the vdevs, depending on the version.
+		header := line[0]
+		if header == "" {
+			header = line[1]
+		}
+		switch header {
+		case "logs", "log":
+			class = &layout.logs
+			continue
+		case "cache":
+			class = &layout.cache
+			continue
+		case "spares", "spare":
+			class = &layout.spares
+			continue
+		case "special", "dedup":
+			// Special allocation classes aren't modelled, so their vdevs are skipped entirely.
+			class = new([]Device)
+			continue
+		}
+
+		// A device being replaced, or replaced by a hot spare, is listed along with its replacement under a replacing-N
+		// or spare-N vdev. zpool list -H doesn't show the nesting, but these vdevs hold the original device followed by
+		// the new one, and only the original is reported until the replacement is done.
+		if interiorVdevPattern.MatchString(line[1]) {
+			interiorDevices = 2
+			continue
+		}
+		if interiorDevices > 0 {
+			interiorDevices--
+			if interiorDevices == 0 {
+				continue
+			}
+		}
+
+		if class != nil {
+			// Mirrored log devices are flattened, as only plain log devices are supported.
+			if !strings.HasPrefix(line[1], "mirror") {
+				device := Device{path: line[1]}
+				if len(line) > 10 {
+					device.state = line[10]
+				}
+				*class = append(*class, device)
+			}
+			continue
+		}
+
 		// All vdevs prefixed with "mirror" indicate the start of a mirrored vdev definition.
 		// mirror* is also a reserved name so we know that if it starts with mirror, it is a mirror.
 		// This is further ensured because we use the -P flag (use full path) with the zpool list
 		// command, meaning all divide vdevs should start with a a forward slash.
+		// The same goes for raidz vdevs, which are named raidz<parity>-<id>.
 		if strings.HasPrefix(line[1], "mirror") {
 			layout.mirrors = append(layout.mirrors, Mirror{
+				name:    line[1],
+				devices: make([]Device, 0),
+			})
+			group = &layout.mirrors[len(layout.mirrors)-1].devices
+		} else if match := raidzVdevPattern.FindStringSubmatch(line[1]); match != nil {
+			parity, err := strconv.Atoi(match[1])
+			if err != nil {
+				return nil, err
+			}
+			layout.raidz = append(layout.raidz, Raidz{
+				name:    line[1],
+				parity:  parity,
 				devices: make([]Device, 0),
 			})
+			group = &layout.raidz[len(layout.raidz)-1].devices
 		} else {
 
-			// If no mirror vdev has been instantiated, this is just a plain striped vdev.
-			if len(layout.mirrors) == 0 {
-				layout.striped = append(layout.striped, Device{
-					path: line[1],
-				})
+			device := Device{path: line[1]}
+			// The health column is the last one reported for vdevs, e.g. ONLINE or OFFLINE.
+			if len(line) > 10 {
+				device.state = line[10]
+			}
+
+			// If no mirror or raidz vdev has been instantiated, this is just a plain striped vdev.
+			if group == nil {
+				layout.striped = append(layout.striped, device)
 			} else {
-				// Otherwise, this vdev belongs to the last defined mirror.
-				mirror := &layout.mirrors[len(layout.mirrors)-1]
-				mirror.devices = append(mirror.devices, Device{path: line[1]})
+				// Otherwise, this vdev belongs to the last defined mirror or raidz vdev.
+				*group = append(*group, device)
 			}
 		}
 	}
 
-	log.Printf("[DEBUG] pool layout: %s", layout)
+	log.Printf("[DEBUG] pool layout: %v", layout)
 
 	return &layout, nil
 }
@@ -560,6 +740,100 @@ func destroyDataset(config *Config, datasetName string) error {
 	return err
 }
 
+func getDatasetMounted(config *Config, datasetName string) (bool, error) {
+	stdout, err := callSshCommand(config, "zfs get -H -o value mounted %s", datasetName)
+	if err != nil {
+		return false, err
+	}
+	return stdout == "yes", nil
+}
+
+func mountDataset(config *Config, datasetName string) error {
+	_, err := callSshCommand(config, "zfs mount %s", datasetName)
+	return err
+}
+
+func unmountDataset(config *Config, datasetName string, force bool) error {
+	serialized_options := ""
+	if force {
+		serialized_options = " -f"
+	}
+	_, err := callSshCommand(config, "zfs unmount%s %s", serialized_options, datasetName)
+	return err
+}
+
+type Rollback struct {
+	snapshot      string
+	destroyNewer  bool
+	destroyClones bool
+	force         bool
+}
+
+func rollbackDataset(config *Config, rollback *Rollback) error {
+	serialized_options := ""
+	// -R also destroys clones of the more recent snapshots, which implies -r.
+	if rollback.destroyClones {
+		serialized_options += " -R"
+	} else if rollback.destroyNewer {
+		serialized_options += " -r"
+	}
+	if rollback.force {
+		serialized_options += " -f"
+	}
+
+	_, err := callSshCommand(config, "zfs rollback%s %s", serialized_options, rollback.snapshot)
+	return err
+}
+
+func createBookmark(config *Config, snapshotName string, bookmarkName string) error {
+	_, err := callSshCommand(config, "zfs bookmark %s %s", snapshotName, bookmarkName)
+	return err
+}
+
+func destroyBookmark(config *Config, bookmarkName string) error {
+	_, err := callSshCommand(config, "zfs destroy %s", bookmarkName)
+	return err
+}
+
+func holdSnapshot(config *Config, snapshotName string, tag string, recursive bool) error {
+	serialized_options := ""
+	if recursive {
+		serialized_options = " -r"
+	}
+	_, err := callSshCommand(config, "zfs hold%s %s %s", serialized_options, shellescape.Quote(tag), snapshotName)
+	return err
+}
+
+func releaseSnapshot(config *Config, snapshotName string, tag string, recursive bool) error {
+	serialized_options := ""
+	if recursive {
+		serialized_options = " -r"
+	}
+	_, err := callSshCommand(config, "zfs release%s %s %s", serialized_options, shellescape.Quote(tag), snapshotName)
+	return err
+}
+
+// parseHolds maps the tags of the holds listed by `zfs holds -H` to the time they were placed.
+func parseHolds(stdout string) map[string]string {
+	holds := make(map[string]string)
+	for _, line := range strings.Split(stdout, "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) < 3 {
+			continue
+		}
+		holds[fields[1]] = fields[2]
+	}
+	return holds
+}
+
+func getSnapshotHolds(config *Config, snapshotName string) (map[string]string, error) {
+	stdout, err := callSshCommand(config, "zfs holds -H %s", snapshotName)
+	if err != nil {
+		return nil, err
+	}
+	return parseHolds(stdout), nil
+}
+
 func renameDataset(config *Config, oldName string, newName string) error {
 	_, err := callSshCommand(config, "zfs rename %s %s", oldName, newName)
 	return err
@@ -599,8 +873,515 @@ func createPool(config *Config, pool *CreatePool) (*Pool, error) {
 	return fetch_pool, fetcherr
 }
 
+type ImportPool struct {
+	name              string
+	guid              string
+	searchDirectories []string
+	altroot           string
+	cachefile         string
+	readonly          bool
+	force             bool
+}
+
+// renderImportArguments renders the options and the pool to import for zpool import.
+func renderImportArguments(pool *ImportPool) string {
+	serialized_options := ""
+	for _, directory := range pool.searchDirectories {
+		serialized_options += fmt.Sprintf(" -d %s", shellescape.Quote(directory))
+	}
+	if pool.altroot != "" {
+		serialized_options += fmt.Sprintf(" -o altroot=%s", shellescape.Quote(pool.altroot))
+	}
+	if pool.cachefile != "" {
+		serialized_options += fmt.Sprintf(" -o cachefile=%s", shellescape.Quote(pool.cachefile))
+	}
+	if pool.readonly {
+		serialized_options += " -o readonly=on"
+	}
+	if pool.force {
+		serialized_options += " -f"
+	}
+
+	// When importing by guid, the name is used as the new name of the pool.
+	target := pool.name
+	if pool.guid != "" {
+		target = fmt.Sprintf("%s %s", pool.guid, pool.name)
+	}
+
+	return fmt.Sprintf("%s %s", serialized_options, target)
+}
+
+func importPool(config *Config, pool *ImportPool) (*Pool, error) {
+	if _, err := callSshCommand(config, "zpool import %s", renderImportArguments(pool)); err != nil {
+		return nil, err
+	}
+
+	return describePool(config, pool.name, []string{})
+}
+
+type SplitPool struct {
+	name    string
+	newName string
+	altroot string
+	devices []string
+}
+
+func splitPool(config *Config, pool *SplitPool) error {
+	serialized_options := ""
+	// The new pool is left exported unless an alternate root to import it under is given.
+	if pool.altroot != "" {
+		serialized_options += fmt.Sprintf(" -R %s", shellescape.Quote(pool.altroot))
+	}
+
+	devices := ""
+	for _, device := range pool.devices {
+		devices += " " + shellescape.Quote(device)
+	}
+
+	_, err := callSshCommand(config, "zpool split %s %s %s%s", serialized_options, pool.name, pool.newName, devices)
+	return err
+}
+
+// validatePoolSplit checks that the pool consists solely of mirrors, and that the devices to split off (if any are
+// given) belong to separate mirrors which would be left with at least one device.
+func validatePoolSplit(layout *PoolLayout, devices []string) error {
+	if len(layout.mirrors) == 0 || len(layout.striped) > 0 || len(layout.raidz) > 0 {
+		return &PoolError{errmsg: "only pools consisting solely of mirrored vdevs can be split"}
+	}
+
+	split := make(map[string]string)
+	for _, device := range devices {
+		found := false
+		for _, mirror := range layout.mirrors {
+			for _, member := range mirror.devices {
+				if !devicePathsMatch(device, member.path) {
+					continue
+				}
+				if other, ok := split[mirror.name]; ok {
+					return fmt.Errorf("devices %s and %s both belong to %s, only one device per mirror can be split off", other, device, mirror.name)
+				}
+				if len(mirror.devices) < 2 {
+					return fmt.Errorf("device %s is the last device of %s and cannot be split off", device, mirror.name)
+				}
+				split[mirror.name] = device
+				found = true
+			}
+		}
+		if !found {
+			return fmt.Errorf("device %s is not part of any mirror in the pool", device)
+		}
+	}
+
+	return nil
+}
+
+// parseImportablePools maps the names of the pools listed by `zpool import` to their guids.
+func parseImportablePools(stdout string) map[string]string {
+	pools := make(map[string]string)
+	name := ""
+	for _, line := range strings.Split(stdout, "\n") {
+		line = strings.TrimSpace(line)
+		if value, ok := strings.CutPrefix(line, "pool:"); ok {
+			name = strings.TrimSpace(value)
+		} else if value, ok := strings.CutPrefix(line, "id:"); ok && name != "" {
+			pools[name] = strings.TrimSpace(value)
+			name = ""
+		}
+	}
+	return pools
+}
+
+func listImportablePools(config *Config) (map[string]string, error) {
+	stdout, err := callSshCommand(config, "zpool import")
+	if err != nil {
+		if strings.Contains(err.Error(), "no pools available to import") {
+			return make(map[string]string), nil
+		}
+		return nil, err
+	}
+	return parseImportablePools(stdout), nil
+}
+
+func exportPool(config *Config, poolName string) error {
+	_, err := callSshCommand(config, "zpool export %s", poolName)
+	return err
+}
+
+func enablePoolFeature(config *Config, poolName string, feature string) error {
+	_, err := callSshCommand(config, "zpool set %s=enabled %s", shellescape.Quote("feature@"+feature), poolName)
+	return err
+}
+
+func upgradePool(config *Config, poolName string) error {
+	_, err := callSshCommand(config, "zpool upgrade %s", poolName)
+	return err
+}
+
+// getFeatureStates returns the state (disabled, enabled or active) of every feature flag supported by the pool.
+func getFeatureStates(properties map[string]Property) map[string]string {
+	features := make(map[string]string)
+	for name, property := range properties {
+		if feature, ok := strings.CutPrefix(name, "feature@"); ok {
+			features[feature] = property.value
+		}
+	}
+	return features
+}
+
+var compatibilityDirectories = []string{"/etc/zfs/compatibility.d", "/usr/share/zfs/compatibility.d"}
+
+// parseCompatibilityFile returns the features listed in a compatibility feature set file, which separates them by
+// whitespace or commas and allows # comments.
+func parseCompatibilityFile(content string) []string {
+	features := make([]string, 0)
+	for _, line := range strings.Split(content, "\n") {
+		line, _, _ = strings.Cut(line, "#")
+		features = append(features, strings.FieldsFunc(line, func(r rune) bool {
+			return r == ',' || r == ' ' || r == '\t'
+		})...)
+	}
+	return features
+}
+
+// intersectFeatures returns the features listed in every one of the feature sets, as zpool only enables those.
+func intersectFeatures(featureSets [][]string) map[string]bool {
+	counts := make(map[string]int)
+	for _, features := range featureSets {
+		seen := make(map[string]bool)
+		for _, feature := range features {
+			if !seen[feature] {
+				seen[feature] = true
+				counts[feature]++
+			}
+		}
+	}
+
+	compatible := make(map[string]bool)
+	for feature, count := range counts {
+		if count == len(featureSets) {
+			compatible[feature] = true
+		}
+	}
+	return compatible
+}
+
+// readCompatibleFeatures returns the features allowed by the compatibility property of a pool, or nil if it doesn't
+// restrict them. Relative file names are looked up in /etc/zfs/compatibility.d before /usr/share/zfs/compatibility.d.
+func readCompatibleFeatures(config *Config, compatibility string) (map[string]bool, error) {
+	switch compatibility {
+	case "", "off", "-":
+		return nil, nil
+	case "legacy":
+		return make(map[string]bool), nil
+	}
+
+	featureSets := make([][]string, 0)
+	for _, name := range strings.Split(compatibility, ",") {
+		paths := []string{name}
+		if !strings.HasPrefix(name, "/") {
+			paths = []string{}
+			for _, directory := range compatibilityDirectories {
+				paths = append(paths, directory+"/"+name)
+			}
+		}
+
+		commands := make([]string, 0)
+		for _, path := range paths {
+			commands = append(commands, "cat "+shellescape.Quote(path)+" 2>/dev/null")
+		}
+		content, err := callShellScript(config, strings.Join(commands, " || "))
+		if err != nil {
+			return nil, err
+		}
+		featureSets = append(featureSets, parseCompatibilityFile(content))
+	}
+	return intersectFeatures(featureSets), nil
+}
+
+// parseDevicesInUse maps the device paths of all vdevs listed by `zpool list -HPv` to the pool they belong to.
+func parseDevicesInUse(stdout string) map[string]string {
+	devices := make(map[string]string)
+	poolName := ""
+	for _, line := range strings.Split(stdout, "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) < 2 {
+			continue
+		}
+		if fields[0] != "" {
+			poolName = fields[0]
+			continue
+		}
+		if strings.HasPrefix(fields[1], "/") {
+			devices[fields[1]] = poolName
+		}
+	}
+	return devices
+}
+
+// canonicalDevicePaths resolves the given paths to the device nodes they point to, so that a disk selected through
+// /dev/disk/by-id compares equal to the /dev/sdX name an imported pool may list it under.
+func canonicalDevicePaths(config *Config, paths []string) (map[string]string, error) {
+	canonical := make(map[string]string)
+	if len(paths) == 0 {
+		return canonical, nil
+	}
+
+	quoted := make([]string, 0)
+	for _, path := range paths {
+		quoted = append(quoted, shellescape.Quote(path))
+	}
+	stdout, err := callSshCommand(config, "readlink -f %s", strings.Join(quoted, " "))
+	if err != nil {
+		return nil, err
+	}
+
+	lines := strings.Split(stdout, "\n")
+	if len(lines) != len(paths) {
+		return nil, fmt.Errorf("expected %d resolved device paths, got %d", len(paths), len(lines))
+	}
+	for i, path := range paths {
+		canonical[path] = lines[i]
+	}
+	return canonical, nil
+}
+
+// findPoolUsingDevice returns the pool using the given disk (or one of its partitions), comparing both the paths as
+// given and the device nodes they resolve to.
+func findPoolUsingDevice(path string, devices map[string]string, canonical map[string]string) string {
+	for device, poolName := range devices {
+		if devicePathsMatch(path, device) {
+			return poolName
+		}
+		if target, ok := canonical[path]; ok && devicePathsMatch(normalizeDevicePath(target), canonical[device]) {
+			return poolName
+		}
+	}
+	return ""
+}
+
+// getPoolUsingDevice returns the name of the imported pool using the given disk (or one of its partitions), if any.
+func getPoolUsingDevice(config *Config, path string) (string, error) {
+	stdout, err := callSshCommand(config, "zpool list -HPv")
+	if err != nil {
+		return "", err
+	}
+
+	devices := parseDevicesInUse(stdout)
+	canonical, err := canonicalDevicePaths(config, append(mapKeys(devices), path))
+	if err != nil {
+		return "", err
+	}
+
+	return findPoolUsingDevice(path, devices, canonical), nil
+}
+
+// hasZfsMember reports whether the `lsblk -nro FSTYPE` output of a disk contains a zfs label on the disk itself or any
+// of its partitions.
+func hasZfsMember(stdout string) bool {
+	for _, line := range strings.Split(stdout, "\n") {
+		if strings.TrimSpace(line) == "zfs_member" {
+			return true
+		}
+	}
+	return false
+}
+
+// isZfsMember reports whether the disk or any of its partitions belong to a pool, which includes exported pools.
+func isZfsMember(config *Config, path string) (bool, error) {
+	stdout, err := callSshCommand(config, "lsblk -nro FSTYPE %s", shellescape.Quote(path))
+	if err != nil {
+		return false, err
+	}
+	return hasZfsMember(stdout), nil
+}
+
+// parseBlockDevices returns the device nodes listed by `lsblk -nrpo NAME`, which are the disk followed by its partitions.
+func parseBlockDevices(stdout string) []string {
+	devices := make([]string, 0)
+	for _, line := range strings.Split(stdout, "\n") {
+		if device := strings.TrimSpace(line); device != "" {
+			devices = append(devices, device)
+		}
+	}
+	return devices
+}
+
+// wipeDevice removes any zfs labels from the device and its partitions, and any other filesystem or partition table
+// signatures from the device.
+func wipeDevice(config *Config, path string) error {
+	stdout, err := callSshCommand(config, "lsblk -nrpo NAME %s", shellescape.Quote(path))
+	if err != nil {
+		return err
+	}
+	for _, device := range parseBlockDevices(stdout) {
+		if _, err := callSshCommand(config, "zpool labelclear -f %s", shellescape.Quote(device)); err != nil {
+			// labelclear fails on devices which don't have a label to begin with, which is fine.
+			log.Printf("[DEBUG] zpool labelclear of %s failed: %s", device, err)
+		}
+	}
+	_, err = callSshCommand(config, "wipefs -a %s", shellescape.Quote(path))
+	return err
+}
+
+type CreatePartition struct {
+	number   int
+	size     string
+	typeCode string
+}
+
+func createPartition(config *Config, path string, partition *CreatePartition) error {
+	_, err := callSshCommand(config, "sgdisk -n %d:0:+%s -t %d:%s %s",
+		partition.number, shellescape.Quote(partition.size), partition.number, shellescape.Quote(partition.typeCode), shellescape.Quote(path))
+	if err != nil {
+		return err
+	}
+	// Wait for udev to create the device node for the new partition, before handing it to zpool.
+	_, err = callSshCommand(config, "udevadm settle")
+	return err
+}
+
+func offlineDevice(config *Config, poolName string, path string) error {
+	_, err := callSshCommand(config, "zpool offline %s %s", poolName, shellescape.Quote(path))
+	return err
+}
+
+func onlineDevice(config *Config, poolName string, path string) error {
+	_, err := callSshCommand(config, "zpool online %s %s", poolName, shellescape.Quote(path))
+	return err
+}
+
+func replaceDevice(config *Config, poolName string, oldPath string, newPath string) error {
+	_, err := callSshCommand(config, "zpool replace %s %s %s", poolName, shellescape.Quote(oldPath), shellescape.Quote(newPath))
+	return err
+}
+
+func addVdevs(config *Config, poolName string, vdevs string) error {
+	_, err := callSshCommand(config, "zpool add %s %s", poolName, vdevs)
+	return err
+}
+
+func removeVdev(config *Config, poolName string, vdev string) error {
+	_, err := callSshCommand(config, "zpool remove %s %s", poolName, shellescape.Quote(vdev))
+	return err
+}
+
+func attachDevice(config *Config, poolName string, vdevName string, path string) error {
+	_, err := callSshCommand(config, "zpool attach %s %s %s", poolName, vdevName, shellescape.Quote(path))
+	return err
+}
+
+var (
+	expansionInProgressPattern = regexp.MustCompile(`expand: expansion of (\S+) in progress`)
+)
+
+// parseExpansionStatus reports whether a raidz expansion is in progress according to `zpool status`, and if so, how far
+// along it is.
+func parseExpansionStatus(stdout string) (bool, float64, error) {
+	lines := strings.Split(stdout, "\n")
+	for i, line := range lines {
+		if !expansionInProgressPattern.MatchString(line) {
+			continue
+		}
+		for _, progressLine := range lines[i+1:] {
+			if match := scanProgressPattern.FindStringSubmatch(progressLine); match != nil {
+				progress, err := strconv.ParseFloat(match[1], 64)
+				return true, progress, err
+			}
+		}
+		return true, 0, nil
+	}
+	return false, 0, nil
+}
+
+// waitForRaidzExpansion polls `zpool status` until the running raidz expansion has finished, logging its progress.
+func waitForRaidzExpansion(ctx context.Context, config *Config, poolName string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		stdout, err := callSshCommand(config, "zpool status %s", poolName)
+		if err != nil {
+			return err
+		}
+
+		expanding, progress, err := parseExpansionStatus(stdout)
+		if err != nil {
+			return err
+		}
+		if !expanding {
+			return nil
+		}
+		log.Printf("[INFO] raidz expansion of %s is %.2f%% done", poolName, progress)
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timed out waiting for raidz expansion of %s, which is %.2f%% done", poolName, progress)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(10 * time.Second):
+		}
+	}
+}
+
+type ZfsVersion struct {
+	major int
+	minor int
+	patch int
+}
+
+func (v ZfsVersion) String() string {
+	return fmt.Sprintf("%d.%d.%d", v.major, v.minor, v.patch)
+}
+
+func (v ZfsVersion) atLeast(major int, minor int, patch int) bool {
+	if v.major != major {
+		return v.major > major
+	}
+	if v.minor != minor {
+		return v.minor > minor
+	}
+	return v.patch >= patch
+}
+
+var zfsVersionPattern = regexp.MustCompile(`^zfs(?:-kmod)?-(\d+)\.(\d+)\.(\d+)`)
+
+// parseZfsVersion parses the output of `zfs version`, which lists both the userland and kernel module versions. The
+// lowest of the two is returned, since both need to support a feature for it to be usable.
+func parseZfsVersion(stdout string) (*ZfsVersion, error) {
+	var lowest *ZfsVersion
+	for _, line := range strings.Split(stdout, "\n") {
+		match := zfsVersionPattern.FindStringSubmatch(strings.TrimSpace(line))
+		if match == nil {
+			continue
+		}
+		version := ZfsVersion{}
+		for i, part := range []*int{&version.major, &version.minor, &version.patch} {
+			value, err := strconv.Atoi(match[i+1])
+			if err != nil {
+				return nil, err
+			}
+			*part = value
+		}
+		if lowest == nil || !version.atLeast(lowest.major, lowest.minor, lowest.patch) {
+			lowest = &version
+		}
+	}
+	if lowest == nil {
+		return nil, fmt.Errorf("unrecognized zfs version: %s", stdout)
+	}
+	return lowest, nil
+}
+
+func getOpenZfsVersion(config *Config) (*ZfsVersion, error) {
+	stdout, err := callSshCommand(config, "zfs version")
+	if err != nil {
+		return nil, err
+	}
+	return parseZfsVersion(stdout)
+}
+
 func renamePool(config *Config, oldName string, newName string) error {
-	_, err := callSshCommand(config, "zpool export %s", oldName)
+	err := exportPool(config, oldName)
 	if err != nil {
 		return err
 	}
@@ -615,6 +1396,351 @@ func destroyPool(config *Config, poolName string) error {
 	return err
 }
 
+type ScanState string
+
+const (
+	ScanNone     ScanState = "none"
+	ScanScanning ScanState = "scanning"
+	ScanFinished ScanState = "finished"
+	ScanCanceled ScanState = "canceled"
+	ScanPaused   ScanState = "paused"
+)
+
+type ScanStatus struct {
+	function string
+	state    ScanState
+	repaired string
+	errors   int
+	start    string
+	end      string
+	progress float64
+}
+
+var (
+	scanScrubFinishedPattern    = regexp.MustCompile(`^scrub repaired (\S+) in (.+?) with (\d+) errors on (.+)$`)
+	scanResilverFinishedPattern = regexp.MustCompile(`^resilvered (\S+) in (.+?) with (\d+) errors on (.+)$`)
+	scanInProgressPattern       = regexp.MustCompile(`^(scrub|resilver) in progress since (.+)$`)
+	scanCanceledPattern         = regexp.MustCompile(`^(scrub|resilver) canceled on (.+)$`)
+	scanPausedPattern           = regexp.MustCompile(`^(scrub) paused since (.+)$`)
+	scanProgressPattern         = regexp.MustCompile(`([\d.]+)% done`)
+)
+
+// parseScanStatus extracts the status of the last scrub or resilver from the "scan:" section of `zpool status`.
+func parseScanStatus(stdout string) (*ScanStatus, error) {
+	lines := strings.Split(stdout, "\n")
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "scan:") {
+			continue
+		}
+		scan := strings.TrimSpace(strings.TrimPrefix(line, "scan:"))
+
+		if scan == "none requested" {
+			return &ScanStatus{state: ScanNone}, nil
+		}
+		if match := scanScrubFinishedPattern.FindStringSubmatch(scan); match != nil {
+			errors, err := strconv.Atoi(match[3])
+			if err != nil {
+				return nil, err
+			}
+			return &ScanStatus{function: "scrub", state: ScanFinished, repaired: match[1], errors: errors, end: match[4]}, nil
+		}
+		if match := scanResilverFinishedPattern.FindStringSubmatch(scan); match != nil {
+			errors, err := strconv.Atoi(match[3])
+			if err != nil {
+				return nil, err
+			}
+			return &ScanStatus{function: "resilver", state: ScanFinished, repaired: match[1], errors: errors, end: match[4]}, nil
+		}
+		if match := scanInProgressPattern.FindStringSubmatch(scan); match != nil {
+			status := &ScanStatus{function: match[1], state: ScanScanning, start: match[2]}
+			// The progress of a running scan is reported on the lines following the scan line itself.
+			for _, progressLine := range lines[i+1:] {
+				if strings.HasPrefix(strings.TrimSpace(progressLine), "config:") {
+					break
+				}
+				if progress := scanProgressPattern.FindStringSubmatch(progressLine); progress != nil {
+					value, err := strconv.ParseFloat(progress[1], 64)
+					if err != nil {
+						return nil, err
+					}
+					status.progress = value
+				}
+			}
+			return status, nil
+		}
+		if match := scanCanceledPattern.FindStringSubmatch(scan); match != nil {
+			return &ScanStatus{function: match[1], state: ScanCanceled, end: match[2]}, nil
+		}
+		if match := scanPausedPattern.FindStringSubmatch(scan); match != nil {
+			return &ScanStatus{function: match[1], state: ScanPaused, start: match[2]}, nil
+		}
+		return nil, fmt.Errorf("unrecognized scan status: %s", scan)
+	}
+
+	// Pools which have never been scrubbed or resilvered may not have a scan line at all.
+	return &ScanStatus{state: ScanNone}, nil
+}
+
+func readScanStatus(config *Config, poolName string) (*ScanStatus, error) {
+	stdout, err := callSshCommand(config, "zpool status %s", poolName)
+	if err != nil {
+		return nil, err
+	}
+	return parseScanStatus(stdout)
+}
+
+type VdevStatus struct {
+	name     string
+	parent   string
+	class    string
+	state    string
+	read     int
+	write    int
+	checksum int
+	message  string
+}
+
+type PoolStatus struct {
+	state  string
+	scan   ScanStatus
+	vdevs  []VdevStatus
+	errors []string
+}
+
+// Section headers in the config tree of `zpool status` which denote special allocation classes.
+var vdevClasses = []string{"logs", "cache", "spares", "special", "dedup"}
+
+func isVdevClass(name string) bool {
+	for _, class := range vdevClasses {
+		if name == class {
+			return true
+		}
+	}
+	return false
+}
+
+// parsePoolStatus parses the output of `zpool status -Pvp` for a single pool.
+func parsePoolStatus(stdout string) (*PoolStatus, error) {
+	scan, err := parseScanStatus(stdout)
+	if err != nil {
+		return nil, err
+	}
+
+	status := PoolStatus{
+		scan:   *scan,
+		vdevs:  make([]VdevStatus, 0),
+		errors: make([]string, 0),
+	}
+
+	section := ""
+	// Names of the vdevs leading up to the current line, indexed by their depth in the tree.
+	parents := make([]string, 0)
+	for _, line := range strings.Split(stdout, "\n") {
+		trimmed := strings.TrimSpace(line)
+
+		switch {
+		case strings.HasPrefix(trimmed, "state:") && section == "":
+			status.state = strings.TrimSpace(strings.TrimPrefix(trimmed, "state:"))
+			continue
+		case strings.HasPrefix(trimmed, "config:"):
+			section = "config"
+			continue
+		case strings.HasPrefix(trimmed, "errors:"):
+			section = "errors"
+			continue
+		}
+
+		switch section {
+		case "config":
+			if trimmed == "" || strings.HasPrefix(trimmed, "NAME") {
+				continue
+			}
+
+			// The tree is indented by a single tab, followed by two spaces per level.
+			indent := len(strings.TrimPrefix(line, "\t")) - len(strings.TrimLeft(strings.TrimPrefix(line, "\t"), " "))
+			depth := indent / 2
+			fields := strings.Fields(trimmed)
+
+			if depth > len(parents) {
+				return nil, fmt.Errorf("unexpected indentation in pool config: %s", line)
+			}
+			parents = parents[:depth]
+			parents = append(parents, fields[0])
+
+			// The first entry at the root is the pool itself, the remaining ones are allocation class headers.
+			if depth == 0 {
+				continue
+			}
+
+			vdev := VdevStatus{
+				name:   fields[0],
+				parent: parents[depth-1],
+				class:  "data",
+			}
+			if isVdevClass(parents[0]) {
+				vdev.class = parents[0]
+			}
+			if len(fields) > 1 {
+				vdev.state = fields[1]
+			}
+			if len(fields) > 4 {
+				counters := make([]int, 3)
+				for i, field := range fields[2:5] {
+					counter, err := strconv.Atoi(field)
+					if err != nil {
+						return nil, fmt.Errorf("invalid error counter for vdev %s: %s", vdev.name, err)
+					}
+					counters[i] = counter
+				}
+				vdev.read, vdev.write, vdev.checksum = counters[0], counters[1], counters[2]
+				vdev.message = strings.Join(fields[5:], " ")
+			}
+			status.vdevs = append(status.vdevs, vdev)
+		case "errors":
+			if trimmed == "" {
+				continue
+			}
+			status.errors = append(status.errors, trimmed)
+		}
+	}
+
+	return &status, nil
+}
+
+func readPoolStatus(config *Config, poolName string) (*PoolStatus, error) {
+	stdout, err := callSshCommand(config, "zpool status -Pvp %s", poolName)
+	if err != nil {
+		return nil, err
+	}
+	return parsePoolStatus(stdout)
+}
+
+func startScrub(config *Config, poolName string) error {
+	_, err := callSshCommand(config, "zpool scrub %s", poolName)
+	return err
+}
+
+func pauseScrub(config *Config, poolName string) error {
+	_, err := callSshCommand(config, "zpool scrub -p %s", poolName)
+	return err
+}
+
+func cancelScrub(config *Config, poolName string) error {
+	_, err := callSshCommand(config, "zpool scrub -s %s", poolName)
+	return err
+}
+
+type TrimState string
+
+const (
+	TrimNone      TrimState = "none"
+	TrimRunning   TrimState = "running"
+	TrimSuspended TrimState = "suspended"
+	TrimCompleted TrimState = "completed"
+)
+
+// parseTrimStatus derives the trim state of the pool from the trim status `zpool status -t` prints after each device,
+// such as "(12% trimmed, started at ...)" or "(12% trimmed, suspended, started at ...)". The pool is trimming as long
+// as any of its devices are. Canceled trims aren't reported, so they show up as none.
+func parseTrimStatus(stdout string) TrimState {
+	suspended, completed := false, false
+	for _, line := range strings.Split(stdout, "\n") {
+		_, trim, ok := strings.Cut(line, "% trimmed")
+		if !ok {
+			continue
+		}
+		switch {
+		case strings.Contains(trim, "suspended"):
+			suspended = true
+		case strings.Contains(trim, "completed"):
+			completed = true
+		default:
+			return TrimRunning
+		}
+	}
+	if suspended {
+		return TrimSuspended
+	}
+	if completed {
+		return TrimCompleted
+	}
+	return TrimNone
+}
+
+func readTrimState(config *Config, poolName string) (TrimState, error) {
+	stdout, err := callSshCommand(config, "zpool status -t %s", poolName)
+	if err != nil {
+		return "", err
+	}
+	return parseTrimStatus(stdout), nil
+}
+
+func startTrim(config *Config, poolName string) error {
+	_, err := callSshCommand(config, "zpool trim %s", poolName)
+	return err
+}
+
+func suspendTrim(config *Config, poolName string) error {
+	_, err := callSshCommand(config, "zpool trim -s %s", poolName)
+	return err
+}
+
+func cancelTrim(config *Config, poolName string) error {
+	_, err := callSshCommand(config, "zpool trim -c %s", poolName)
+	return err
+}
+
+// waitForPoolActivity blocks until the given activity (scrub, trim, resilver, ...) has finished on the pool.
+func waitForPoolActivity(config *Config, poolName string, activity string, timeout time.Duration) error {
+	_, err := callSshCommandWithTimeout(config, timeout, "zpool wait -t %s %s", activity, poolName)
+	return err
+}
+
+func createPoolCheckpoint(config *Config, poolName string) error {
+	_, err := callSshCommand(config, "zpool checkpoint %s", poolName)
+	return err
+}
+
+func discardPoolCheckpoint(config *Config, poolName string) error {
+	_, err := callSshCommand(config, "zpool checkpoint -d %s", poolName)
+	return err
+}
+
+// parseCheckpointStatus returns the checkpoint line of `zpool status`, such as "created Sun Oct 18 00:24:01 2026,
+// consumes 1.06M", or an empty string if the pool has no checkpoint.
+func parseCheckpointStatus(stdout string) string {
+	for _, line := range strings.Split(stdout, "\n") {
+		if status, ok := strings.CutPrefix(strings.TrimSpace(line), "checkpoint:"); ok {
+			return strings.TrimSpace(status)
+		}
+	}
+	return ""
+}
+
+// readPoolCheckpoint returns the space used by the checkpoint of the pool, or nil if the pool has no checkpoint. The
+// checkpoint property reads "-" while the checkpoint consumes no space, so zpool status is used to tell if it exists.
+func readPoolCheckpoint(config *Config, poolName string) (*Property, error) {
+	stdout, err := callSshCommand(config, "zpool status %s", poolName)
+	if err != nil {
+		return nil, err
+	}
+	if status := parseCheckpointStatus(stdout); status == "" || strings.HasPrefix(status, "discarding") {
+		return nil, nil
+	}
+
+	properties := make(map[string]Property)
+	if err := readSomeProperties(config, "zpool", poolName, "checkpoint", properties); err != nil {
+		return nil, err
+	}
+
+	checkpoint := properties["checkpoint"]
+	if checkpoint.rawValue == "-" || checkpoint.rawValue == "" {
+		checkpoint.rawValue = "0"
+	}
+	return &checkpoint, nil
+}
+
 func flattenProperties(properties map[string]Property) map[string]interface{} {
 	out := make(map[string]interface{})
 	for name, property := range properties {
@@ -625,6 +1751,26 @@ func flattenProperties(properties map[string]Property) map[string]interface{} {
 	return out
 }
 
+func flattenPropertySources(properties map[string]Property) map[string]interface{} {
+	out := make(map[string]interface{})
+	for name, property := range properties {
+		out[name] = string(property.source)
+	}
+
+	return out
+}
+
+func flattenInheritedFrom(properties map[string]Property) map[string]interface{} {
+	out := make(map[string]interface{})
+	for name, property := range properties {
+		if property.source == SourceInherited {
+			out[name] = property.inheritedFrom
+		}
+	}
+
+	return out
+}
+
 func flattenRawProperties(properties map[string]Property) map[string]interface{} {
 	out := make(map[string]interface{})
 	for name, property := range properties {
@@ -647,9 +1793,36 @@ func flattenMirror(mirror Mirror) map[string]interface{} {
 	return out
 }
 
+func flattenRaidz(raidz Raidz) map[string]interface{} {
+	out := make(map[string]interface{})
+	devices := make([]map[string]interface{}, len(raidz.devices))
+	for device_id, device := range raidz.devices {
+		devices[device_id] = flattenDevice(device)
+	}
+	out["parity"] = raidz.parity
+	out["device"] = devices
+
+	return out
+}
+
 func flattenDevice(device Device) map[string]interface{} {
 	out := make(map[string]interface{})
 	out["path"] = device.path
+	out["offline"] = device.state == "OFFLINE"
+
+	return out
+}
+
+func flattenVdevStatus(vdev VdevStatus) map[string]interface{} {
+	out := make(map[string]interface{})
+	out["name"] = vdev.name
+	out["parent"] = vdev.parent
+	out["class"] = vdev.class
+	out["state"] = vdev.state
+	out["read_errors"] = vdev.read
+	out["write_errors"] = vdev.write
+	out["checksum_errors"] = vdev.checksum
+	out["message"] = vdev.message
 
 	return out
 }